@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nzin/lexneo4j/internal/redact"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Outcomes of the audited queries
+const (
+	OutcomeSuccess  = "success"
+	OutcomeDenied   = "denied"
+	OutcomeRejected = "rejected"
+	OutcomeTimeout  = "timeout"
+	OutcomeCanceled = "canceled"
+	OutcomeError    = "error"
+)
+
+// Entry is the audit record of a query, written as one JSON line
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Endpoint is the path of the request running the query
+	Endpoint string `json:"endpoint"`
+	// Caller is the name of the authenticated caller (empty if not authenticated)
+	Caller string `json:"caller,omitempty"`
+	// Tenant is the tenant of the caller, the query being scoped to it
+	Tenant string `json:"tenant,omitempty"`
+	// Query is the query as sent by the caller
+	Query string `json:"query"`
+	// RenderedQuery is the query as sent to neo4j (empty if the query was rejected before)
+	RenderedQuery string `json:"renderedQuery,omitempty"`
+	// Parameters are the parameters sent to neo4j with the query
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Rows       int                    `json:"rows"`
+	DurationMs float64                `json:"durationMs"`
+	Outcome    string                 `json:"outcome"`
+	Status     int                    `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// NewEntry starts the audit record of a query, its duration being measured from now
+func NewEntry(endpoint string, query string) *Entry {
+	return &Entry{
+		Time:     time.Now(),
+		Endpoint: endpoint,
+		Query:    query,
+		Outcome:  OutcomeSuccess,
+		Status:   200,
+	}
+}
+
+// Succeed records the number of rows returned by the query
+func (e *Entry) Succeed(rows int) {
+	e.Rows = rows
+	e.Outcome = OutcomeSuccess
+	e.Status = 200
+}
+
+// Fail records why the query failed, the outcome being deduced from the status code of the response
+func (e *Entry) Fail(status int, message string) {
+	e.Status = status
+	e.Error = message
+	switch {
+	case status == 403:
+		e.Outcome = OutcomeDenied
+	case status == 504:
+		e.Outcome = OutcomeTimeout
+	case status == 499:
+		e.Outcome = OutcomeCanceled
+	case status >= 400 && status < 500:
+		e.Outcome = OutcomeRejected
+	default:
+		e.Outcome = OutcomeError
+	}
+}
+
+// FileConfig configures the rotation of the audit file
+type FileConfig struct {
+	Path string
+	// MaxSizeMB is the size of the file before it is rotated
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files kept (0 to keep all of them)
+	MaxBackups int
+	// MaxAgeDays is the number of days the rotated files are kept (0 to keep them forever)
+	MaxAgeDays int
+	// Compress gzips the rotated files
+	Compress bool
+}
+
+// Logger appends the audit entries to a sink, separated from the application log
+type Logger struct {
+	mu         sync.Mutex
+	w          io.WriteCloser
+	parameters map[string]bool
+}
+
+// NewLogger returns a logger writing to w, masking the values of the parameters whose name is in redactParameters
+func NewLogger(w io.WriteCloser, redactParameters []string) *Logger {
+	parameters := map[string]bool{}
+	for _, p := range redactParameters {
+		parameters[p] = true
+	}
+	return &Logger{w: w, parameters: parameters}
+}
+
+// NewFileLogger returns a logger appending to a file rotated by size, or nil if there is no file
+func NewFileLogger(config FileConfig, redactParameters []string) *Logger {
+	if config.Path == "" {
+		return nil
+	}
+	return NewLogger(&lumberjack.Logger{
+		Filename:   config.Path,
+		MaxSize:    config.MaxSizeMB,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAgeDays,
+		Compress:   config.Compress,
+	}, redactParameters)
+}
+
+// Log completes the entry with its duration, and writes it. A nil logger does nothing.
+func (l *Logger) Log(e *Entry) {
+	if l == nil {
+		return
+	}
+	e.DurationMs = float64(time.Since(e.Time).Microseconds()) / 1000
+
+	entry := *e
+	if len(e.Parameters) > 0 {
+		entry.Parameters = map[string]interface{}{}
+		for k, v := range e.Parameters {
+			if l.parameters[k] {
+				v = redact.Mask
+			}
+			entry.Parameters[k] = v
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logrus.WithField("err", err).Error("cannot marshal the audit entry")
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		logrus.WithField("err", err).Error("cannot write the audit entry")
+	}
+}
+
+// Close closes the sink. A nil logger does nothing.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Close()
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type buffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *buffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func lines(t *testing.T, content []byte) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		entry := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogger(t *testing.T) {
+	b := &buffer{}
+	l := NewLogger(b, []string{"ssn"})
+
+	entry := NewEntry("/api/v1/cypher", "MATCH (p:Person) RETURN p.name")
+	entry.Caller = "alice"
+	entry.Tenant = "acme"
+	entry.RenderedQuery = "MATCH (p:Person{tenant:'acme'}) RETURN p.name LIMIT 1000"
+	entry.Parameters = map[string]interface{}{"ssn": "123-45-6789", "name": "Tom"}
+	entry.Succeed(3)
+	l.Log(entry)
+	// only the written line is redacted
+	assert.Equal(t, "123-45-6789", entry.Parameters["ssn"])
+
+	denied := NewEntry("/api/v1/cypher", "MATCH (s:Secret) RETURN s")
+	denied.Fail(403, "label 'Secret' is not allowed for roles [anonymous]")
+	l.Log(denied)
+
+	entries := lines(t, b.Bytes())
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "alice", entries[0]["caller"])
+	assert.Equal(t, "acme", entries[0]["tenant"])
+	assert.Equal(t, "MATCH (p:Person) RETURN p.name", entries[0]["query"])
+	assert.Equal(t, "MATCH (p:Person{tenant:'acme'}) RETURN p.name LIMIT 1000", entries[0]["renderedQuery"])
+	assert.Equal(t, map[string]interface{}{"ssn": "[REDACTED]", "name": "Tom"}, entries[0]["parameters"])
+	assert.Equal(t, float64(3), entries[0]["rows"])
+	assert.Equal(t, "success", entries[0]["outcome"])
+	assert.Contains(t, entries[0], "durationMs")
+	assert.Contains(t, entries[0], "time")
+
+	assert.Equal(t, "denied", entries[1]["outcome"])
+	assert.Equal(t, float64(403), entries[1]["status"])
+	assert.NotContains(t, entries[1], "caller")
+	assert.NotContains(t, entries[1], "renderedQuery")
+
+	assert.Nil(t, l.Close())
+	assert.True(t, b.closed)
+
+	t.Run("outcomes", func(t *testing.T) {
+		for status, outcome := range map[int]string{
+			403: OutcomeDenied,
+			422: OutcomeRejected,
+			499: OutcomeCanceled,
+			504: OutcomeTimeout,
+			500: OutcomeError,
+		} {
+			entry := NewEntry("/api/v1/cypher", "")
+			entry.Fail(status, "")
+			assert.Equal(t, outcome, entry.Outcome, status)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		l := NewFileLogger(FileConfig{Path: path, MaxSizeMB: 1}, nil)
+		l.Log(NewEntry("/api/v1/movies", "MATCH (m:Movie) RETURN m.title,m.released"))
+		l.Log(NewEntry("/api/v1/movies", "MATCH (m:Movie) RETURN m.title,m.released"))
+		assert.Nil(t, l.Close())
+
+		content, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Len(t, lines(t, content), 2)
+	})
+
+	t.Run("no file", func(t *testing.T) {
+		l := NewFileLogger(FileConfig{}, nil)
+		assert.Nil(t, l)
+		l.Log(NewEntry("/api/v1/cypher", ""))
+		assert.Nil(t, l.Close())
+	})
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIKeyHeader is the header holding the API key of a request
+const APIKeyHeader = "X-API-Key"
+
+// APIKey is an API key allowed to call the API. Only the SHA-256 of the key is kept, i.e. as generated by
+// "echo -n $KEY | sha256sum".
+type APIKey struct {
+	Name string `yaml:"name" json:"name"`
+	// Hash is the hex encoded SHA-256 of the key
+	Hash  string   `yaml:"hash" json:"hash"`
+	Roles []string `yaml:"roles" json:"roles"`
+}
+
+// APIKeys authenticates the requests by their API key
+type APIKeys struct {
+	keys map[string]*APIKey
+}
+
+// apiKeysFile is the content of an API keys file
+//
+//	keys:
+//	  - name: frontend
+//	    hash: 5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8
+//	    roles: [reader]
+type apiKeysFile struct {
+	Keys []APIKey `yaml:"keys" json:"keys"`
+}
+
+// LoadAPIKeysFile loads the API keys from a YAML or JSON file
+func LoadAPIKeysFile(path string) ([]APIKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := apiKeysFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid API keys file: %v", err)
+	}
+	return file.Keys, nil
+}
+
+// ParseAPIKeys parses API keys given as "name:hash" pairs (without roles)
+func ParseAPIKeys(pairs []string) ([]APIKey, error) {
+	keys := []APIKey{}
+	for _, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid API key: %s, should be name:hash", pair)
+		}
+		keys = append(keys, APIKey{Name: parts[0], Hash: parts[1]})
+	}
+	return keys, nil
+}
+
+// NewAPIKeys creates the API key authenticator. It returns nil (no API key authentication) if there are no keys.
+func NewAPIKeys(keys []APIKey) (*APIKeys, error) {
+	a := APIKeys{
+		keys: map[string]*APIKey{},
+	}
+	for i := range keys {
+		key := &keys[i]
+		hash, err := hex.DecodeString(key.Hash)
+		if key.Name == "" || err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("invalid API key '%s': a name and a hex encoded SHA-256 hash are expected", key.Name)
+		}
+		a.keys[strings.ToLower(key.Hash)] = key
+	}
+	if len(a.keys) == 0 {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+// Authenticate returns the identity of the API key of the request
+func (a *APIKeys) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+	hash := sha256.Sum256([]byte(key))
+	apiKey, ok := a.keys[hex.EncodeToString(hash[:])]
+	if !ok {
+		return nil, fmt.Errorf("invalid API key (%s header)", APIKeyHeader)
+	}
+	return &Identity{Name: apiKey.Name, Roles: apiKey.Roles}, nil
+}
+
+// Scheme returns the API key authentication scheme
+func (a *APIKeys) Scheme() string {
+	return "ApiKey"
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// serve runs a request through the middleware, returning the response and the identity seen by the next handler
+func serve(m *Middleware, path string, headers map[string]string) (*httptest.ResponseRecorder, *Identity) {
+	var identity *Identity
+	next := func(rw http.ResponseWriter, r *http.Request) {
+		identity = IdentityFromContext(r.Context())
+	}
+	r := httptest.NewRequest(http.MethodPost, path, nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, r, next)
+	return rw, identity
+}
+
+func apiKey(key string) map[string]string {
+	return map[string]string{APIKeyHeader: key}
+}
+
+func TestAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys([]string{"frontend:" + hashKey("secret"), "batch:" + strings.ToUpper(hashKey("other"))})
+	assert.Nil(t, err)
+	a, err := NewAPIKeys(keys)
+	assert.Nil(t, err)
+	m := NewMiddleware([]Authenticator{a}, []string{"/api/v1/health"})
+
+	t.Run("valid key", func(t *testing.T) {
+		rw, identity := serve(m, "/api/v1/cypher", apiKey("secret"))
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.NotNil(t, identity)
+		assert.Equal(t, "frontend", identity.Name)
+
+		_, identity = serve(m, "/api/v1/cypher", apiKey("other"))
+		assert.Equal(t, "batch", identity.Name)
+	})
+
+	t.Run("missing or invalid key", func(t *testing.T) {
+		for _, headers := range []map[string]string{nil, apiKey("wrong")} {
+			rw, identity := serve(m, "/api/v1/cypher", headers)
+			assert.Equal(t, http.StatusUnauthorized, rw.Code)
+			assert.Nil(t, identity)
+			assert.Equal(t, "ApiKey", rw.Header().Get("WWW-Authenticate"))
+			assert.Contains(t, rw.Body.String(), `"message"`)
+		}
+	})
+
+	t.Run("exempted urls", func(t *testing.T) {
+		for _, path := range []string{"/api/v1/health", "/api/v1/health/ready"} {
+			rw, identity := serve(m, path, nil)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Nil(t, identity)
+		}
+		rw, _ := serve(m, "/api/v1/healthz", nil)
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	})
+
+	t.Run("keys file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.yaml")
+		content := "keys:\n  - name: frontend\n    hash: " + hashKey("secret") + "\n    roles: [reader]\n"
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+
+		keys, err := LoadAPIKeysFile(path)
+		assert.Nil(t, err)
+		a, err := NewAPIKeys(keys)
+		assert.Nil(t, err)
+		_, identity := serve(NewMiddleware([]Authenticator{a}, nil), "/api/v1/cypher", apiKey("secret"))
+		assert.Equal(t, []string{"reader"}, identity.Roles)
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		_, err := ParseAPIKeys([]string{"frontend"})
+		assert.NotNil(t, err)
+		_, err = NewAPIKeys([]APIKey{{Name: "frontend", Hash: "secret"}})
+		assert.NotNil(t, err)
+		_, err = NewAPIKeys([]APIKey{{Hash: hashKey("secret")}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		a, err := NewAPIKeys(nil)
+		assert.Nil(t, err)
+		assert.Nil(t, a)
+		assert.Nil(t, NewMiddleware(nil, nil))
+	})
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ClientCert maps the subject of a client certificate to the identity of the caller
+type ClientCert struct {
+	// Subject is the distinguished name of the certificate, i.e. "CN=reporting,OU=analytics,O=Acme"
+	Subject string `yaml:"subject" json:"subject"`
+	// Name is the name of the caller, the common name of the certificate if empty
+	Name   string   `yaml:"name" json:"name"`
+	Roles  []string `yaml:"roles" json:"roles"`
+	Tenant string   `yaml:"tenant" json:"tenant"`
+}
+
+// ClientCerts authenticates the requests by the client certificate verified during the TLS handshake
+type ClientCerts struct {
+	// subjects are the allowed subjects, all the verified certificates being allowed if nil
+	subjects map[string]*ClientCert
+}
+
+// clientCertsFile is the content of a client certificates file
+//
+//	subjects:
+//	  - subject: CN=reporting,OU=analytics,O=Acme
+//	    name: reporting
+//	    roles: [reader]
+//	    tenant: acme
+type clientCertsFile struct {
+	Subjects []ClientCert `yaml:"subjects" json:"subjects"`
+}
+
+// LoadClientCertsFile loads the subjects of the client certificates from a YAML or JSON file
+func LoadClientCertsFile(path string) ([]ClientCert, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := clientCertsFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid client certificates file: %v", err)
+	}
+	return file.Subjects, nil
+}
+
+// NewClientCerts creates the client certificate authenticator. Without subjects, the caller of any verified
+// certificate is authenticated by its common name, without roles nor tenant. Otherwise, only the certificates of
+// the given subjects are allowed.
+func NewClientCerts(subjects []ClientCert) (*ClientCerts, error) {
+	c := &ClientCerts{}
+	for i := range subjects {
+		subject := &subjects[i]
+		if subject.Subject == "" {
+			return nil, fmt.Errorf("invalid client certificate: a subject is expected")
+		}
+		if c.subjects == nil {
+			c.subjects = map[string]*ClientCert{}
+		}
+		c.subjects[subject.Subject] = subject
+	}
+	return c, nil
+}
+
+// Authenticate returns the identity of the verified client certificate of the request, nil if the client did not
+// send a certificate
+func (c *ClientCerts) Authenticate(r *http.Request) (*Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if c.subjects == nil {
+		return &Identity{Name: cert.Subject.CommonName}, nil
+	}
+
+	subject, ok := c.subjects[cert.Subject.String()]
+	if !ok {
+		return nil, fmt.Errorf("client certificate not allowed: %s", cert.Subject.String())
+	}
+	name := subject.Name
+	if name == "" {
+		name = cert.Subject.CommonName
+	}
+	return &Identity{Name: name, Roles: subject.Roles, Tenant: subject.Tenant}, nil
+}
+
+// Scheme returns the client certificate authentication scheme
+func (c *ClientCerts) Scheme() string {
+	return "ClientCert"
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nzin/lexneo4j/internal/tlsconfig/tlstest"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveTLS starts a TLS server verifying the client certificates, answering with the identity of the caller
+func serveTLS(t *testing.T, ca *tlstest.CA, m *Middleware) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		m.ServeHTTP(rw, r, func(rw http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(rw).Encode(IdentityFromContext(r.Context()))
+		})
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{ca.Server("server").TLS},
+		ClientCAs:    ca.Pool(),
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// getTLS calls the server with a client certificate (if not nil), returning the status and the identity of the caller
+func getTLS(t *testing.T, server *httptest.Server, ca *tlstest.CA, cert *tlstest.Cert) (int, *Identity) {
+	clientConfig := &tls.Config{RootCAs: ca.Pool()}
+	if cert != nil {
+		clientConfig.Certificates = []tls.Certificate{cert.TLS}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+	resp, err := client.Get(server.URL + "/api/v1/cypher")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	var identity *Identity
+	if resp.StatusCode == http.StatusOK {
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&identity))
+	}
+	return resp.StatusCode, identity
+}
+
+func TestClientCerts(t *testing.T) {
+	ca := tlstest.NewCA(t, "ca")
+	reporting := ca.Client("reporting", pkix.Name{CommonName: "reporting", OrganizationalUnit: []string{"analytics"}, Organization: []string{"Acme"}})
+	batch := ca.Client("batch", pkix.Name{CommonName: "batch", Organization: []string{"Acme"}})
+
+	t.Run("common name", func(t *testing.T) {
+		c, err := NewClientCerts(nil)
+		assert.Nil(t, err)
+		server := serveTLS(t, ca, NewMiddleware([]Authenticator{c}, nil))
+
+		status, identity := getTLS(t, server, ca, reporting)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, &Identity{Name: "reporting"}, identity)
+
+		status, _ = getTLS(t, server, ca, nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("mapped subjects", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "client-certs.yaml")
+		assert.Nil(t, os.WriteFile(path, []byte(`
+subjects:
+  - subject: CN=reporting,OU=analytics,O=Acme
+    name: reporting-service
+    roles: [reader]
+    tenant: acme
+`), 0600))
+		subjects, err := LoadClientCertsFile(path)
+		assert.Nil(t, err)
+		c, err := NewClientCerts(subjects)
+		assert.Nil(t, err)
+		server := serveTLS(t, ca, NewMiddleware([]Authenticator{c}, nil))
+
+		status, identity := getTLS(t, server, ca, reporting)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, &Identity{Name: "reporting-service", Roles: []string{"reader"}, Tenant: "acme"}, identity)
+
+		// a verified certificate whose subject is not mapped
+		status, _ = getTLS(t, server, ca, batch)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("invalid subjects", func(t *testing.T) {
+		_, err := NewClientCerts([]ClientCert{{Name: "reporting"}})
+		assert.NotNil(t, err)
+
+		path := filepath.Join(t.TempDir(), "client-certs.yaml")
+		assert.Nil(t, os.WriteFile(path, []byte("subjects:\n  - dn: CN=reporting\n"), 0600))
+		_, err = LoadClientCertsFile(path)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("plain http", func(t *testing.T) {
+		c, err := NewClientCerts(nil)
+		assert.Nil(t, err)
+		identity, err := c.Authenticate(httptest.NewRequest(http.MethodGet, "/api/v1/cypher", nil))
+		assert.Nil(t, identity)
+		assert.Nil(t, err)
+	})
+}
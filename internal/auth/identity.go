@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// Name identifies the caller in the logs, i.e. the name of its API key or the subject of its token
+	Name string
+	// Roles are the roles of the caller, used to evaluate the access policy
+	Roles []string
+	// Tenant scopes the queries of the caller (no scoping if empty)
+	Tenant string
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of the context holding the identity of the caller
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// IdentityFromContext returns the identity of the caller, or nil if the caller is not authenticated
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(contextKey{}).(*Identity)
+	return identity
+}
+
+// Authenticator authenticates a request with one kind of credentials
+type Authenticator interface {
+	// Authenticate returns the identity of the caller, nil if the request does not hold this kind of credentials, or
+	// an error if the credentials are invalid
+	Authenticate(r *http.Request) (*Identity, error)
+	// Scheme is the authentication scheme, returned in the WWW-Authenticate header
+	Scheme() string
+}
+
+// Middleware is the negroni middleware authenticating the requests with the first authenticator recognizing their
+// credentials, the identity of the caller being set in the request context
+type Middleware struct {
+	authenticators []Authenticator
+	exemptURLs     []string
+}
+
+// NewMiddleware creates the authentication middleware, the exempted URLs (and the URLs below them) being reachable
+// without credentials. It returns nil (no authentication) if there are no authenticators.
+func NewMiddleware(authenticators []Authenticator, exemptURLs []string) *Middleware {
+	if len(authenticators) == 0 {
+		return nil
+	}
+	return &Middleware{
+		authenticators: authenticators,
+		exemptURLs:     exemptURLs,
+	}
+}
+
+// ServeHTTP rejects the requests without valid credentials with a 401
+func (m *Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if isExempt(m.exemptURLs, r.URL.Path) {
+		next(rw, r)
+		return
+	}
+
+	for _, authenticator := range m.authenticators {
+		identity, err := authenticator.Authenticate(r)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"err": err, "path": r.URL.Path}).Warn("request rejected: invalid credentials")
+			m.unauthorized(rw, err.Error())
+			return
+		}
+		if identity != nil {
+			logrus.WithFields(logrus.Fields{"caller": identity.Name, "method": r.Method, "path": r.URL.Path}).Info("authenticated request")
+			next(rw, r.WithContext(WithIdentity(r.Context(), identity)))
+			return
+		}
+	}
+
+	logrus.WithField("path", r.URL.Path).Warn("request rejected: missing credentials")
+	m.unauthorized(rw, "missing credentials")
+}
+
+// unauthorized writes a 401 response, with the same payload as the API errors
+func (m *Middleware) unauthorized(rw http.ResponseWriter, message string) {
+	for _, authenticator := range m.authenticators {
+		rw.Header().Add("WWW-Authenticate", authenticator.Scheme())
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(rw).Encode(map[string]string{"message": message})
+}
+
+// isExempt returns true if the path is one of the exempted URLs, or below one of them
+func isExempt(exemptURLs []string, path string) bool {
+	for _, u := range exemptURLs {
+		if path == u || strings.HasPrefix(path, strings.TrimSuffix(u, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
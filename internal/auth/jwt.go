@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig configures the verification of the JWT bearer tokens, and how their claims map to the caller identity
+type JWTConfig struct {
+	// Secret is the shared secret of the HS256 tokens (no HS256 if empty)
+	Secret string
+	// Keys are the public keys of the RS256 / ES256 tokens, by key id (kid)
+	Keys map[string]crypto.PublicKey
+	// Issuer and Audience are checked if not empty
+	Issuer   string
+	Audience string
+	// NameClaim, RolesClaim and TenantClaim are the claims holding the name, the roles (as a list or a space separated
+	// string) and the tenant of the caller. A nested claim is given with a dotted path, i.e. "realm_access.roles".
+	// Without TenantClaim, the callers have no tenant.
+	NameClaim   string
+	RolesClaim  string
+	TenantClaim string
+	// AllowNoTenant lets the tokens without the tenant claim through, their callers having no tenant (their queries
+	// are not scoped). Otherwise these tokens are rejected.
+	AllowNoTenant bool
+}
+
+// JWT authenticates the requests by their JWT bearer token
+type JWT struct {
+	config JWTConfig
+	parser *jwt.Parser
+}
+
+// NewJWT creates the JWT authenticator. It returns nil (no JWT authentication) if there is neither a secret nor keys.
+func NewJWT(config JWTConfig) *JWT {
+	methods := []string{}
+	if config.Secret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(config.Keys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	return &JWT{
+		config: config,
+		parser: jwt.NewParser(options...),
+	}
+}
+
+// Authenticate returns the identity of the bearer token of the request
+func (j *JWT) Authenticate(r *http.Request) (*Identity, error) {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) < len("Bearer ") || !strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return nil, nil
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := j.parser.ParseWithClaims(strings.TrimSpace(authorization[len("Bearer "):]), claims, j.key); err != nil {
+		return nil, fmt.Errorf("invalid bearer token: %v", err)
+	}
+
+	name, _ := claim(claims, j.config.NameClaim).(string)
+	if name == "" {
+		return nil, fmt.Errorf("invalid bearer token: missing '%s' claim", j.config.NameClaim)
+	}
+	tenant := ""
+	if j.config.TenantClaim != "" {
+		tenant, _ = claim(claims, j.config.TenantClaim).(string)
+		if tenant == "" && !j.config.AllowNoTenant {
+			return nil, fmt.Errorf("invalid bearer token: missing '%s' claim", j.config.TenantClaim)
+		}
+	}
+	return &Identity{
+		Name:   name,
+		Roles:  roles(claim(claims, j.config.RolesClaim)),
+		Tenant: tenant,
+	}, nil
+}
+
+// Scheme returns the bearer authentication scheme
+func (j *JWT) Scheme() string {
+	return "Bearer"
+}
+
+// key returns the key verifying the token, depending on its algorithm (and on its key id for the public keys)
+func (j *JWT) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return []byte(j.config.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := j.config.Keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(j.config.Keys) == 1 {
+		for _, key := range j.config.Keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id '%s'", kid)
+}
+
+// claim returns the value of a (possibly nested) claim, or nil
+func claim(claims jwt.MapClaims, path string) interface{} {
+	var value interface{} = map[string]interface{}(claims)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// roles returns the roles of a claim, given as a list or as a space separated string
+func roles(value interface{}) []string {
+	roles := []string{}
+	switch value := value.(type) {
+	case string:
+		roles = append(roles, strings.Fields(value)...)
+	case []interface{}:
+		for _, role := range value {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+	}
+	return roles
+}
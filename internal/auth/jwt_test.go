@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeJWKS writes a JWKS file with the public keys of an RSA and of an EC key
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+		},
+	}
+	content, err := json.Marshal(jwks)
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, os.WriteFile(path, content, 0600))
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key crypto.PrivateKey, claims jwt.MapClaims) map[string]string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return map[string]string{"Authorization": "Bearer " + s}
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	keys, err := LoadJWKSFile(writeJWKS(t, rsaKey, ecKey))
+	assert.Nil(t, err)
+	assert.Len(t, keys, 2)
+
+	secret := "shared-secret"
+	j := NewJWT(JWTConfig{
+		Secret:      secret,
+		Keys:        keys,
+		Issuer:      "gateway",
+		NameClaim:   "sub",
+		RolesClaim:  "realm_access.roles",
+		TenantClaim: "tenant",
+	})
+	m := NewMiddleware([]Authenticator{j}, nil)
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":          "alice",
+			"iss":          "gateway",
+			"exp":          time.Now().Add(time.Hour).Unix(),
+			"tenant":       "acme",
+			"realm_access": map[string]interface{}{"roles": []interface{}{"reader", "admin"}},
+		}
+	}
+
+	t.Run("HS256", func(t *testing.T) {
+		rw, identity := serve(m, "/api/v1/cypher", sign(t, jwt.SigningMethodHS256, "", []byte(secret), claims()))
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, &Identity{Name: "alice", Roles: []string{"reader", "admin"}, Tenant: "acme"}, identity)
+	})
+
+	t.Run("RS256 and ES256", func(t *testing.T) {
+		_, identity := serve(m, "/api/v1/cypher", sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims()))
+		assert.NotNil(t, identity)
+		assert.Equal(t, "alice", identity.Name)
+
+		_, identity = serve(m, "/api/v1/cypher", sign(t, jwt.SigningMethodES256, "ec", ecKey, claims()))
+		assert.NotNil(t, identity)
+		assert.Equal(t, "acme", identity.Tenant)
+	})
+
+	t.Run("roles as a string", func(t *testing.T) {
+		j := NewJWT(JWTConfig{Secret: secret, NameClaim: "sub", RolesClaim: "scope", TenantClaim: "tenant"})
+		c := claims()
+		c["scope"] = "reader writer"
+		identity, err := j.Authenticate(newRequest(sign(t, jwt.SigningMethodHS256, "", []byte(secret), c)))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"reader", "writer"}, identity.Roles)
+	})
+
+	t.Run("without tenant", func(t *testing.T) {
+		c := claims()
+		delete(c, "tenant")
+		headers := sign(t, jwt.SigningMethodHS256, "", []byte(secret), c)
+
+		j := NewJWT(JWTConfig{Secret: secret, NameClaim: "sub", TenantClaim: "tenant", AllowNoTenant: true})
+		identity, err := j.Authenticate(newRequest(headers))
+		assert.Nil(t, err)
+		assert.Equal(t, "", identity.Tenant)
+
+		j = NewJWT(JWTConfig{Secret: secret, NameClaim: "sub"})
+		identity, err = j.Authenticate(newRequest(sign(t, jwt.SigningMethodHS256, "", []byte(secret), claims())))
+		assert.Nil(t, err)
+		assert.Equal(t, "", identity.Tenant)
+	})
+
+	t.Run("invalid tokens", func(t *testing.T) {
+		expired := claims()
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+		noExpiration := claims()
+		delete(noExpiration, "exp")
+		otherIssuer := claims()
+		otherIssuer["iss"] = "somebody"
+		noSubject := claims()
+		delete(noSubject, "sub")
+		noTenant := claims()
+		delete(noTenant, "tenant")
+		tenantNotString := claims()
+		tenantNotString["tenant"] = 42
+
+		for name, headers := range map[string]map[string]string{
+			"wrong secret":  sign(t, jwt.SigningMethodHS256, "", []byte("wrong"), claims()),
+			"expired":       sign(t, jwt.SigningMethodHS256, "", []byte(secret), expired),
+			"no expiration": sign(t, jwt.SigningMethodHS256, "", []byte(secret), noExpiration),
+			"other issuer":  sign(t, jwt.SigningMethodHS256, "", []byte(secret), otherIssuer),
+			"no subject":    sign(t, jwt.SigningMethodHS256, "", []byte(secret), noSubject),
+			"no tenant":     sign(t, jwt.SigningMethodHS256, "", []byte(secret), noTenant),
+			"tenant number": sign(t, jwt.SigningMethodHS256, "", []byte(secret), tenantNotString),
+			"unknown kid":   sign(t, jwt.SigningMethodRS256, "other", rsaKey, claims()),
+			"wrong key":     sign(t, jwt.SigningMethodES256, "rsa", ecKey, claims()),
+			"not allowed":   sign(t, jwt.SigningMethodHS384, "", []byte(secret), claims()),
+			"garbage":       {"Authorization": "Bearer garbage"},
+		} {
+			rw, identity := serve(m, "/api/v1/cypher", headers)
+			assert.Equal(t, http.StatusUnauthorized, rw.Code, name)
+			assert.Nil(t, identity, name)
+			assert.Equal(t, "Bearer", rw.Header().Get("WWW-Authenticate"), name)
+		}
+	})
+
+	t.Run("with API keys", func(t *testing.T) {
+		keys, _ := ParseAPIKeys([]string{"batch:" + hashKey("key")})
+		a, _ := NewAPIKeys(keys)
+		m := NewMiddleware([]Authenticator{a, j}, nil)
+
+		_, identity := serve(m, "/api/v1/cypher", apiKey("key"))
+		assert.Equal(t, "batch", identity.Name)
+		_, identity = serve(m, "/api/v1/cypher", sign(t, jwt.SigningMethodHS256, "", []byte(secret), claims()))
+		assert.Equal(t, "alice", identity.Name)
+		rw, _ := serve(m, "/api/v1/cypher", nil)
+		assert.Equal(t, []string{"ApiKey", "Bearer"}, rw.Header().Values("WWW-Authenticate"))
+	})
+
+	t.Run("no JWT", func(t *testing.T) {
+		assert.Nil(t, NewJWT(JWTConfig{}))
+		_, err := ParseJWKS([]byte(`{"keys":[{"kty":"oct","kid":"k"}]}`))
+		assert.NotNil(t, err)
+	})
+}
+
+func newRequest(headers map[string]string) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "/api/v1/cypher", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	return r
+}
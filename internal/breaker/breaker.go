@@ -0,0 +1,159 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// State is the state of a circuit breaker
+type State string
+
+const (
+	// Closed lets the calls through, counting their consecutive failures
+	Closed State = "closed"
+	// Open fails the calls fast, until the open timeout is elapsed
+	Open State = "open"
+	// HalfOpen lets a single trial call through, closing the breaker if it succeeds, opening it again otherwise
+	HalfOpen State = "half-open"
+)
+
+// ErrOpen is returned instead of calling a service whose circuit breaker is open
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Breaker is a circuit breaker, failing the calls fast once a service failed too many times in a row. A nil Breaker
+// lets all the calls through.
+type Breaker struct {
+	failures    int
+	openTimeout time.Duration
+	// isFailure tells the errors showing the service is failing from the ones caused by the call itself (i.e. an
+	// invalid query), which do not count
+	isFailure func(error) bool
+	now       func() time.Time
+	onChange  func(State)
+
+	mu          sync.Mutex
+	state       State
+	consecutive int
+	openedAt    time.Time
+	// trial is true while the call of the half-open breaker is in flight
+	trial bool
+}
+
+// New creates a circuit breaker opening after the given number of consecutive failures, and half-opening once
+// openTimeout is elapsed. It returns nil if failures is 0, to disable it.
+func New(failures int, openTimeout time.Duration, isFailure func(error) bool) *Breaker {
+	if failures <= 0 {
+		return nil
+	}
+	return &Breaker{
+		failures:    failures,
+		openTimeout: openTimeout,
+		isFailure:   isFailure,
+		now:         time.Now,
+		state:       Closed,
+	}
+}
+
+// OnChange registers a function called with the new state whenever the breaker changes of state
+func (b *Breaker) OnChange(fn func(State)) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = fn
+}
+
+// Do calls fn unless the breaker is open, returning ErrOpen instead, and records its outcome, even if fn panics
+func (b *Breaker) Do(fn func() error) (err error) {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	defer func() { b.Done(err) }()
+	return fn()
+}
+
+// Allow returns ErrOpen if the call must fail fast. Otherwise the outcome of the call must be recorded with Done,
+// deferred not to leave the trial call of a half-open breaker in flight forever if the call panics.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.setState(HalfOpen)
+	}
+	switch b.state {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		// the other calls fail fast until the trial call is done
+		if b.trial {
+			return ErrOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Done records the outcome of a call allowed by Allow
+func (b *Breaker) Done(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := err != nil && b.isFailure(err)
+	switch b.state {
+	case Closed:
+		if !failed {
+			b.consecutive = 0
+			return
+		}
+		b.consecutive++
+		if b.consecutive >= b.failures {
+			b.open()
+		}
+	case HalfOpen:
+		b.trial = false
+		if failed {
+			b.open()
+		} else {
+			b.consecutive = 0
+			b.setState(Closed)
+		}
+	}
+}
+
+// State returns the state of the breaker, reported half-open once the open timeout is elapsed
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.openTimeout {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.setState(Open)
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
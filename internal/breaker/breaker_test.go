@@ -0,0 +1,87 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errUnavailable = errors.New("connection refused")
+	errInvalid     = errors.New("invalid query")
+)
+
+func isFailure(err error) bool {
+	return err == errUnavailable
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := New(3, 10*time.Second, isFailure)
+	b.now = func() time.Time { return now }
+	states := []State{}
+	b.OnChange(func(state State) { states = append(states, state) })
+
+	fail := func() error { return errUnavailable }
+	succeed := func() error { return nil }
+
+	// the failures must be consecutive, the errors of the calls themselves showing the service answers
+	assert.Equal(t, errUnavailable, b.Do(fail))
+	assert.Equal(t, errUnavailable, b.Do(fail))
+	assert.Nil(t, b.Do(succeed))
+	assert.Equal(t, errUnavailable, b.Do(fail))
+	assert.Equal(t, errUnavailable, b.Do(fail))
+	assert.Equal(t, errInvalid, b.Do(func() error { return errInvalid }))
+	assert.Equal(t, errUnavailable, b.Do(fail))
+	assert.Equal(t, errUnavailable, b.Do(fail))
+	assert.Equal(t, Closed, b.State())
+
+	assert.Equal(t, errUnavailable, b.Do(fail))
+	assert.Equal(t, Open, b.State())
+
+	// fails fast without calling
+	called := false
+	assert.Equal(t, ErrOpen, b.Do(func() error { called = true; return nil }))
+	assert.False(t, called)
+
+	// half-opens after the timeout, letting a single trial call through, failing again
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, HalfOpen, b.State())
+	assert.Nil(t, b.Allow())
+	assert.Equal(t, ErrOpen, b.Allow())
+	b.Done(errUnavailable)
+	assert.Equal(t, Open, b.State())
+	assert.Equal(t, ErrOpen, b.Do(succeed))
+
+	// the trial call closes it once it succeeds
+	now = now.Add(10 * time.Second)
+	assert.Nil(t, b.Do(succeed))
+	assert.Equal(t, Closed, b.State())
+	assert.Equal(t, errUnavailable, b.Do(fail))
+	assert.Equal(t, Closed, b.State())
+
+	assert.Equal(t, []State{Open, HalfOpen, Open, HalfOpen, Closed}, states)
+}
+
+func TestPanic(t *testing.T) {
+	b := New(1, 0, isFailure)
+	assert.Equal(t, errUnavailable, b.Do(func() error { return errUnavailable }))
+	assert.Equal(t, HalfOpen, b.State())
+
+	// the trial call panics, which does not leave the breaker waiting for it
+	assert.Panics(t, func() {
+		b.Do(func() error { panic("unexpected value") })
+	})
+	assert.Nil(t, b.Allow())
+}
+
+func TestDisabled(t *testing.T) {
+	b := New(0, time.Second, isFailure)
+	assert.Nil(t, b)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, errUnavailable, b.Do(func() error { return errUnavailable }))
+	}
+	assert.Equal(t, Closed, b.State())
+}
@@ -0,0 +1,269 @@
+package complexity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nzin/lexneo4j/internal/parser"
+)
+
+// Weights of the factors in the score of a query
+const (
+	PatternWeight          = 2
+	UnlabeledNodeWeight    = 3
+	HopWeight              = 2
+	UnboundedHopWeight     = 100
+	CartesianProductWeight = 25
+	UnfilteredMatchWeight  = 10
+)
+
+// Cost is the estimated cost of a query (and of the queries combined with it), as the count of each factor making
+// it expensive
+type Cost struct {
+	// Patterns is the number of patterns, in MATCH clauses, pattern predicates and EXISTS subqueries
+	Patterns int
+	// UnlabeledNodes is the number of nodes of MATCH clauses whose variable is never labeled
+	UnlabeledNodes int
+	// Hops is the sum of the maximum number of hops of the bounded variable-length relationships
+	Hops int
+	// UnboundedHops is the number of variable-length relationships without a maximum number of hops
+	UnboundedHops int
+	// CartesianProducts is the number of patterns disconnected from the rest of their query stage
+	CartesianProducts int
+	// UnfilteredMatches is the number of MATCH clauses without any property filter (neither inline properties nor a
+	// WHERE clause) and not anchored on an already bound variable
+	UnfilteredMatches int
+}
+
+// factor is the contribution of a factor to the score
+type factor struct {
+	name   string
+	count  int
+	weight int
+}
+
+func (c *Cost) factors() []factor {
+	return []factor{
+		{"unbounded variable-length relationship", c.UnboundedHops, UnboundedHopWeight},
+		{"cartesian product", c.CartesianProducts, CartesianProductWeight},
+		{"unfiltered MATCH clause", c.UnfilteredMatches, UnfilteredMatchWeight},
+		{"unlabeled node", c.UnlabeledNodes, UnlabeledNodeWeight},
+		{"variable-length hop", c.Hops, HopWeight},
+		{"pattern", c.Patterns, PatternWeight},
+	}
+}
+
+// Score returns the weighted sum of the factors
+func (c *Cost) Score() int {
+	score := 0
+	for _, f := range c.factors() {
+		score += f.count * f.weight
+	}
+	return score
+}
+
+// Explain details the score, i.e. "1 cartesian product (+25), 2 patterns (+4)"
+func (c *Cost) Explain() string {
+	details := []string{}
+	for _, f := range c.factors() {
+		if f.count == 0 {
+			continue
+		}
+		name := f.name
+		if f.count > 1 {
+			name += "s"
+		}
+		details = append(details, fmt.Sprintf("%d %s (+%d)", f.count, name, f.count*f.weight))
+	}
+	return strings.Join(details, ", ")
+}
+
+// Estimate computes the cost of a query, without running it
+func Estimate(query *parser.CypherQuery) Cost {
+	cost := Cost{}
+	for _, q := range query.Queries() {
+		estimate(q, &cost)
+	}
+	return cost
+}
+
+func estimate(q *parser.CypherQuery, cost *Cost) {
+	labeled := map[string]bool{}
+	q.WalkPatterns(func(node *parser.CypherNode, rel *parser.CypherRelationShip) {
+		cost.Patterns++
+		for _, n := range patternNodes(node, rel) {
+			if n.VariableName != nil && n.TypeName != nil {
+				labeled[*n.VariableName] = true
+			}
+		}
+		if rel != nil && rel.Hops != nil {
+			if rel.Hops.Max == nil {
+				cost.UnboundedHops++
+			} else {
+				cost.Hops += *rel.Hops.Max
+			}
+		}
+	})
+
+	for _, m := range q.MatchClauses() {
+		nodes := []*parser.CypherNode{&m.Node}
+		if m.Relationship != nil {
+			nodes = append(nodes, &m.Relationship.Target)
+		}
+		for _, n := range nodes {
+			if n.TypeName == nil && (n.VariableName == nil || !labeled[*n.VariableName]) {
+				cost.UnlabeledNodes++
+			}
+		}
+	}
+
+	// the stages of the query, separated by the WITH clauses
+	stage := append([]parser.CypherMatch{{Node: q.MatchNode, Relationship: q.Relationship, Where: q.Where}}, q.Matches...)
+	estimateStage(nil, stage, cost)
+	for _, w := range q.With {
+		bound := []string{}
+		for _, proj := range w.Projections {
+			bound = append(bound, proj.Name())
+		}
+		estimateStage(bound, w.Matches, cost)
+	}
+}
+
+// estimateStage counts the cartesian products and the unfiltered matches of the MATCH clauses of a query stage, the
+// bound variables being the ones projected by the previous WITH clause
+func estimateStage(bound []string, matches []parser.CypherMatch, cost *Cost) {
+	if len(matches) == 0 {
+		return
+	}
+
+	components := newUnionFind()
+	scope := map[string]bool{}
+	roots := []string{}
+	if len(bound) > 0 {
+		roots = append(roots, "#with")
+		for _, variable := range bound {
+			components.union("#with", variable)
+			scope[variable] = true
+		}
+	}
+
+	for i, m := range matches {
+		id := fmt.Sprintf("#%d", i)
+		roots = append(roots, id)
+		nodes := patternNodes(&m.Node, m.Relationship)
+
+		anchored := false
+		filtered := m.Where != nil
+		for _, n := range nodes {
+			if len(n.Props) > 0 {
+				filtered = true
+			}
+			if n.VariableName != nil {
+				anchored = anchored || scope[*n.VariableName]
+				components.union(id, *n.VariableName)
+			}
+		}
+		if !filtered && !anchored {
+			cost.UnfilteredMatches++
+		}
+		for _, n := range nodes {
+			if n.VariableName != nil {
+				scope[*n.VariableName] = true
+			}
+		}
+	}
+
+	distinct := map[string]bool{}
+	for _, r := range roots {
+		distinct[components.find(r)] = true
+	}
+	cost.CartesianProducts += len(distinct) - 1
+}
+
+// Thresholds are the maximum cost of a query. A negative maximum means no maximum.
+type Thresholds struct {
+	MaxScore             int
+	MaxPatterns          int
+	MaxUnlabeledNodes    int
+	MaxUnboundedHops     int
+	MaxCartesianProducts int
+}
+
+// Rejection explains why a query is too complex
+type Rejection struct {
+	Cost    Cost
+	Reasons []string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("query is too complex: %s", strings.Join(r.Reasons, "; "))
+}
+
+// Check estimates the cost of the query and returns a *Rejection if it exceeds the thresholds. It returns nil if the
+// query is cheap enough, or if there are no thresholds.
+func (t *Thresholds) Check(query *parser.CypherQuery) error {
+	if t == nil {
+		return nil
+	}
+
+	cost := Estimate(query)
+	reasons := []string{}
+	if score := cost.Score(); t.MaxScore >= 0 && score > t.MaxScore {
+		reasons = append(reasons, fmt.Sprintf("score %d exceeds %d (%s)", score, t.MaxScore, cost.Explain()))
+	}
+	for _, max := range []struct {
+		name  string
+		count int
+		max   int
+	}{
+		{"patterns", cost.Patterns, t.MaxPatterns},
+		{"unlabeled nodes", cost.UnlabeledNodes, t.MaxUnlabeledNodes},
+		{"unbounded variable-length relationships", cost.UnboundedHops, t.MaxUnboundedHops},
+		{"cartesian products", cost.CartesianProducts, t.MaxCartesianProducts},
+	} {
+		if max.max >= 0 && max.count > max.max {
+			reasons = append(reasons, fmt.Sprintf("%d %s exceed the maximum of %d", max.count, max.name, max.max))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+	return &Rejection{Cost: cost, Reasons: reasons}
+}
+
+// patternNodes returns the nodes of a pattern: the node, and if there is a relationship, the relationship (as a node,
+// if it has brackets) and its target
+func patternNodes(node *parser.CypherNode, rel *parser.CypherRelationShip) []*parser.CypherNode {
+	if rel == nil {
+		return []*parser.CypherNode{node}
+	}
+	if rel.Props == nil {
+		return []*parser.CypherNode{node, &rel.Target}
+	}
+	return []*parser.CypherNode{node, rel.Props, &rel.Target}
+}
+
+// unionFind groups the variables and the patterns connected together
+type unionFind map[string]string
+
+func newUnionFind() unionFind {
+	return unionFind{}
+}
+
+func (u unionFind) find(x string) string {
+	parent, ok := u[x]
+	if !ok || parent == x {
+		return x
+	}
+	root := u.find(parent)
+	u[x] = root
+	return root
+}
+
+func (u unionFind) union(x, y string) {
+	rootX, rootY := u.find(x), u.find(y)
+	if rootX != rootY {
+		u[rootX] = rootY
+	}
+}
@@ -0,0 +1,88 @@
+package complexity
+
+import (
+	"testing"
+
+	"github.com/nzin/lexneo4j/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func estimateQuery(t *testing.T, s string) Cost {
+	query, err := parser.NewParser(s).Parse()
+	assert.Nil(t, err)
+	return Estimate(query)
+}
+
+func TestEstimate(t *testing.T) {
+	t.Run("cheap query", func(t *testing.T) {
+		cost := estimateQuery(t, "MATCH (m:Movie{title:'The Matrix'})<-[:ACTED_IN]-(a:Person) RETURN a.name")
+		assert.Equal(t, Cost{Patterns: 1}, cost)
+		assert.Equal(t, 2, cost.Score())
+	})
+
+	t.Run("unlabeled nodes", func(t *testing.T) {
+		cost := estimateQuery(t, "MATCH (n)-->() RETURN n")
+		assert.Equal(t, 2, cost.UnlabeledNodes)
+		assert.Equal(t, 1, cost.UnfilteredMatches)
+
+		// a variable labeled somewhere else in the query is not unlabeled
+		cost = estimateQuery(t, "MATCH (m:Movie) WHERE m.released > 2000 OPTIONAL MATCH (m)<-[:DIRECTED]-(d:Person) RETURN m, d")
+		assert.Equal(t, 0, cost.UnlabeledNodes)
+		assert.Equal(t, 0, cost.UnfilteredMatches)
+	})
+
+	t.Run("variable-length relationships", func(t *testing.T) {
+		cost := estimateQuery(t, "MATCH (a:Person{name:'Tom'})-[:KNOWS*1..3]->(b:Person) WHERE EXISTS { MATCH (b)-[*]-(c) } RETURN b")
+		assert.Equal(t, 3, cost.Hops)
+		assert.Equal(t, 1, cost.UnboundedHops)
+		assert.Equal(t, 2, cost.Patterns)
+	})
+
+	t.Run("cartesian products", func(t *testing.T) {
+		cost := estimateQuery(t, "MATCH (a:Person) MATCH (m:Movie) OPTIONAL MATCH (a)-[:DIRECTED]->(d:Movie) RETURN a, m, d")
+		assert.Equal(t, 1, cost.CartesianProducts)
+		assert.Equal(t, 2, cost.UnfilteredMatches)
+
+		// the variables projected by a WITH connect the following patterns
+		cost = estimateQuery(t, "MATCH (a:Person{name:'Tom'}) WITH a MATCH (a)-->(m:Movie) RETURN m")
+		assert.Equal(t, 0, cost.CartesianProducts)
+		assert.Equal(t, 0, cost.UnfilteredMatches)
+
+		cost = estimateQuery(t, "MATCH (a:Person{name:'Tom'}) WITH a MATCH (m:Movie) RETURN a, m")
+		assert.Equal(t, 1, cost.CartesianProducts)
+		assert.Equal(t, 1, cost.UnfilteredMatches)
+	})
+
+	t.Run("union", func(t *testing.T) {
+		cost := estimateQuery(t, "MATCH (a:Person) RETURN a.name AS name UNION MATCH (m:Movie) RETURN m.title AS name")
+		assert.Equal(t, Cost{Patterns: 2, UnfilteredMatches: 2}, cost)
+		assert.Equal(t, "2 unfiltered MATCH clauses (+20), 2 patterns (+4)", cost.Explain())
+	})
+}
+
+func TestThresholds(t *testing.T) {
+	thresholds := &Thresholds{MaxScore: 100, MaxPatterns: -1, MaxUnlabeledNodes: -1, MaxUnboundedHops: -1, MaxCartesianProducts: 1}
+
+	check := func(s string) error {
+		query, err := parser.NewParser(s).Parse()
+		assert.Nil(t, err)
+		return thresholds.Check(query)
+	}
+
+	assert.Nil(t, check("MATCH (a:Person)-[:ACTED_IN]->(m:Movie) RETURN a, m"))
+
+	err := check("MATCH (a)-[*]->(b) RETURN a, b")
+	assert.NotNil(t, err)
+	rejection, ok := err.(*Rejection)
+	assert.True(t, ok)
+	assert.Equal(t, 1, rejection.Cost.UnboundedHops)
+	assert.Equal(t, "query is too complex: score 118 exceeds 100 (1 unbounded variable-length relationship (+100), 1 unfiltered MATCH clause (+10), 2 unlabeled nodes (+6), 1 pattern (+2))", err.Error())
+
+	err = check("MATCH (a:Person{name:'Tom'}) MATCH (b:Person{name:'Meg'}) MATCH (m:Movie{title:'Cloud Atlas'}) RETURN a, b, m")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "2 cartesian products exceed the maximum of 1")
+
+	var noThresholds *Thresholds
+	query, _ := parser.NewParser("MATCH (a)-[*]->(b) RETURN a, b").Parse()
+	assert.Nil(t, noThresholds.Check(query))
+}
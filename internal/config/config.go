@@ -2,30 +2,41 @@ package config
 
 import (
 	"os"
+	"strconv"
 
-	"github.com/caarlos0/env"
 	"github.com/sirupsen/logrus"
 )
 
 func init() {
-	env.Parse(&Config)
+	configFile = configFilePath(os.Args[1:])
+	c, settingSources, err := load(configFile)
+	if err != nil {
+		logrus.WithField("err", err).Fatal("invalid configuration")
+	}
+	if err := validate(&c); err != nil {
+		logrus.Fatalf("invalid configuration:\n%v", err)
+	}
+	Config, sources = c, settingSources
+
+	// the server reads its host and port from the environment when parsing its flags, after init
+	if sources["HOST"] == SourceFile {
+		os.Setenv("HOST", Config.Host)
+	}
+	if sources["PORT"] == SourceFile {
+		os.Setenv("PORT", strconv.Itoa(Config.Port))
+	}
 
 	setupLogrus()
 }
 
+// setupLogrus sets the logrus level and format up, once validated
 func setupLogrus() {
-	l, err := logrus.ParseLevel(Config.LogrusLevel)
-	if err != nil {
-		logrus.WithField("err", err).Fatalf("failed to set logrus level:%s", Config.LogrusLevel)
-	}
+	l, _ := logrus.ParseLevel(Config.LogrusLevel)
 	logrus.SetLevel(l)
 	logrus.SetOutput(os.Stdout)
-	switch Config.LogrusFormat {
-	case "text":
+	if Config.LogrusFormat == "text" {
 		logrus.SetFormatter(&logrus.TextFormatter{})
-	case "json":
+	} else {
 		logrus.SetFormatter(&logrus.JSONFormatter{})
-	default:
-		logrus.Warnf("unexpected logrus format: %s, should be one of: text, json", Config.LogrusFormat)
 	}
 }
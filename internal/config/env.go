@@ -1,14 +1,28 @@
 package config
 
-// Config is the whole configuration of the app
-var Config = struct {
+import "time"
+
+// Configuration is the whole configuration of the app. Each setting is read from its environment variable, or else
+// from the configuration file. The settings tagged reload are hot reloaded from the configuration file, and the ones
+// tagged secret are redacted from /admin/config.
+type Configuration struct {
 	// Host - golang-skeleton server host
 	Host string `env:"HOST" envDefault:"localhost"`
 	// Port - golang-skeleton server port
 	Port int `env:"PORT" envDefault:"18000"`
 
+	// TLSCertFile - PEM certificate (with its chain) of the server, for the https scheme (--scheme https)
+	TLSCertFile string `env:"LEXNEO4J_TLS_CERT_FILE" envDefault:""`
+	// TLSKeyFile - PEM private key of the server certificate
+	TLSKeyFile string `env:"LEXNEO4J_TLS_KEY_FILE" envDefault:""`
+	// TLSClientAuth - verification of the client certificates
+	// Possible values: none, optional, require
+	TLSClientAuth string `env:"LEXNEO4J_TLS_CLIENT_AUTH" envDefault:"none"`
+	// TLSClientCAFile - PEM bundle of the certificate authorities trusted to verify the client certificates
+	TLSClientCAFile string `env:"LEXNEO4J_TLS_CLIENT_CA_FILE" envDefault:""`
+
 	// LogrusLevel sets the logrus logging level
-	LogrusLevel string `env:"LEXNEO4J_LOGRUS_LEVEL" envDefault:"info"`
+	LogrusLevel string `env:"LEXNEO4J_LOGRUS_LEVEL" envDefault:"info" reload:"true"`
 	// LogrusFormat sets the logrus logging formatter
 	// Possible values: text, json
 	LogrusFormat string `env:"LEXNEO4J_LOGRUS_FORMAT" envDefault:"json"`
@@ -20,9 +34,161 @@ var Config = struct {
 	// MiddlewareGzipEnabled - to enable gzip middleware
 	MiddlewareGzipEnabled bool `env:"LEXNEO4J_MIDDLEWARE_GZIP_ENABLED" envDefault:"true"`
 
+	// MetricsEnabled - to expose the prometheus metrics
+	MetricsEnabled bool `env:"LEXNEO4J_METRICS_ENABLED" envDefault:"true"`
+	// MetricsPath - path of the prometheus metrics, not authenticated nor rate limited
+	MetricsPath string `env:"LEXNEO4J_METRICS_PATH" envDefault:"/metrics"`
+
+	// TracingEnabled - to export OpenTelemetry spans with OTLP over HTTP, the exporter being configured with the
+	// standard OTEL_EXPORTER_OTLP_* variables (i.e. OTEL_EXPORTER_OTLP_ENDPOINT)
+	TracingEnabled bool `env:"LEXNEO4J_TRACING_ENABLED" envDefault:"false"`
+	// TracingServiceName - service name of the exported spans
+	TracingServiceName string `env:"LEXNEO4J_TRACING_SERVICE_NAME" envDefault:"lexneo4j"`
+	// TracingSampleRatio - ratio of the traces sampled, when the callers do not send a sampled traceparent header
+	TracingSampleRatio float64 `env:"LEXNEO4J_TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	// AuthAPIKeysFile - YAML or JSON file listing the API keys (name, SHA-256 hash and roles) allowed to call the API
+	AuthAPIKeysFile string `env:"LEXNEO4J_AUTH_API_KEYS_FILE" envDefault:"" reload:"true"`
+	// AuthAPIKeys - API keys allowed to call the API via comma separated list of name:hash (the hash being the hex
+	// encoded SHA-256 of the key). Without any API key (file or list), the API is not authenticated.
+	AuthAPIKeys []string `env:"LEXNEO4J_AUTH_API_KEYS" envDefault:"" envSeparator:"," secret:"true" reload:"true"`
+	// AuthJWTSecret - shared secret of the HS256 JWT bearer tokens allowed to call the API
+	AuthJWTSecret string `env:"LEXNEO4J_AUTH_JWT_SECRET" envDefault:"" secret:"true"`
+	// AuthJWTJWKSFile - JWKS file with the public keys of the RS256 / ES256 JWT bearer tokens allowed to call the API
+	AuthJWTJWKSFile string `env:"LEXNEO4J_AUTH_JWT_JWKS_FILE" envDefault:""`
+	// AuthJWTIssuer - expected issuer (iss claim) of the JWT bearer tokens (not checked if empty)
+	AuthJWTIssuer string `env:"LEXNEO4J_AUTH_JWT_ISSUER" envDefault:""`
+	// AuthJWTAudience - expected audience (aud claim) of the JWT bearer tokens (not checked if empty)
+	AuthJWTAudience string `env:"LEXNEO4J_AUTH_JWT_AUDIENCE" envDefault:""`
+	// AuthJWTNameClaim - claim of the JWT bearer tokens naming the caller (dotted path for nested claims)
+	AuthJWTNameClaim string `env:"LEXNEO4J_AUTH_JWT_NAME_CLAIM" envDefault:"sub"`
+	// AuthJWTRolesClaim - claim of the JWT bearer tokens listing the caller roles, as a list or a space separated string
+	AuthJWTRolesClaim string `env:"LEXNEO4J_AUTH_JWT_ROLES_CLAIM" envDefault:"roles"`
+	// AuthJWTTenantClaim - claim of the JWT bearer tokens giving the caller tenant, the queries of the callers with a
+	// tenant being scoped to it (empty if the callers have no tenant)
+	AuthJWTTenantClaim string `env:"LEXNEO4J_AUTH_JWT_TENANT_CLAIM" envDefault:"tenant"`
+	// AuthJWTAllowNoTenant - to let the JWT bearer tokens without the tenant claim through, their queries not being
+	// scoped to any tenant (rejected otherwise)
+	AuthJWTAllowNoTenant bool `env:"LEXNEO4J_AUTH_JWT_ALLOW_NO_TENANT" envDefault:"false"`
+	// AuthClientCertEnabled - to authenticate the callers by their verified TLS client certificate, the common name of
+	// the certificate being the name of the caller unless its subject is mapped in AuthClientCertsFile
+	AuthClientCertEnabled bool `env:"LEXNEO4J_AUTH_CLIENT_CERT_ENABLED" envDefault:"false"`
+	// AuthClientCertsFile - YAML or JSON file mapping the subjects of the client certificates to the callers, with their
+	// roles and tenant (all the verified certificates being allowed if empty)
+	AuthClientCertsFile string `env:"LEXNEO4J_AUTH_CLIENT_CERTS_FILE" envDefault:""`
+	// AuthExemptURLs - to exempt urls (and the urls below them) from the authentication via comma separated list
+	AuthExemptURLs []string `env:"LEXNEO4J_AUTH_EXEMPT_URLS" envDefault:"/api/v1/health" envSeparator:","`
+
+	// RateLimitRate - number of requests per second allowed per caller (or per IP for the callers that are not
+	// authenticated) on average, the requests above being rejected with a 429 (0 to disable)
+	RateLimitRate float64 `env:"LEXNEO4J_RATE_LIMIT_RATE" envDefault:"0" reload:"true"`
+	// RateLimitBurst - number of requests a caller can send at once, above the rate
+	RateLimitBurst int `env:"LEXNEO4J_RATE_LIMIT_BURST" envDefault:"10" reload:"true"`
+	// RateLimitMaxConcurrentPerCaller - maximum number of queries running at once per caller (0 to disable)
+	RateLimitMaxConcurrentPerCaller int `env:"LEXNEO4J_RATE_LIMIT_MAX_CONCURRENT_PER_CALLER" envDefault:"2" reload:"true"`
+	// RateLimitMaxConcurrent - maximum number of queries running at once for all the callers (0 to disable), to keep
+	// some neo4j connections available
+	RateLimitMaxConcurrent int `env:"LEXNEO4J_RATE_LIMIT_MAX_CONCURRENT" envDefault:"8" reload:"true"`
+	// RateLimitConcurrencyURLs - urls running queries, whose concurrency is limited, via comma separated list
+	RateLimitConcurrencyURLs []string `env:"LEXNEO4J_RATE_LIMIT_CONCURRENCY_URLS" envDefault:"/api/v1/cypher,/api/v1/cypher/explain,/api/v1/movies" envSeparator:"," reload:"true"`
+	// RateLimitExemptURLs - to exempt urls (and the urls below them) from the limits via comma separated list
+	RateLimitExemptURLs []string `env:"LEXNEO4J_RATE_LIMIT_EXEMPT_URLS" envDefault:"/api/v1/health" envSeparator:"," reload:"true"`
+
+	// AuditFile - file receiving the audit log of the executed queries, as JSON lines (no audit log if empty)
+	AuditFile string `env:"LEXNEO4J_AUDIT_FILE" envDefault:""`
+	// AuditMaxSizeMB - size in megabytes of the audit file before it is rotated
+	AuditMaxSizeMB int `env:"LEXNEO4J_AUDIT_MAX_SIZE_MB" envDefault:"100"`
+	// AuditMaxBackups - number of rotated audit files kept (0 to keep all of them)
+	AuditMaxBackups int `env:"LEXNEO4J_AUDIT_MAX_BACKUPS" envDefault:"0"`
+	// AuditMaxAgeDays - number of days the rotated audit files are kept (0 to keep them forever)
+	AuditMaxAgeDays int `env:"LEXNEO4J_AUDIT_MAX_AGE_DAYS" envDefault:"0"`
+	// AuditCompress - to gzip the rotated audit files
+	AuditCompress bool `env:"LEXNEO4J_AUDIT_COMPRESS" envDefault:"false"`
+	// AuditRedactParameters - query parameters whose value is masked in the audit log via comma separated list
+	AuditRedactParameters []string `env:"LEXNEO4J_AUDIT_REDACT_PARAMETERS" envDefault:"" envSeparator:","`
+
 	// neo4j configuration
 	//Neo4jURL      string `env:"NEO4J_URL" envDefault:"bolt://neo4j:7687/neo4j"`
 	Neo4jURL      string `env:"NEO4J_URL" envDefault:"neo4j://localhost:7687/neo4j"`
 	Neo4jUsername string `env:"NEO4J_USERNAME" envDefault:"neo4j"`
-	Neo4jPassword string `env:"NEO4J_PASSWORD" envDefault:"password"`
-}{}
+	Neo4jPassword string `env:"NEO4J_PASSWORD" envDefault:"password" secret:"true"`
+	// Neo4jCAFile - PEM bundle of the certificate authorities trusted to verify neo4j with the bolt+s and neo4j+s
+	// schemes (the system ones if empty)
+	Neo4jCAFile string `env:"NEO4J_CA_FILE" envDefault:""`
+	// Neo4jConnectTimeout - how long the startup retries to connect to neo4j, with a backoff, before giving up
+	Neo4jConnectTimeout time.Duration `env:"NEO4J_CONNECT_TIMEOUT" envDefault:"2m"`
+	// Neo4jAcquisitionTimeout - how long a query waits for a connection of the driver pool, or for neo4j to accept a
+	// new one, before failing
+	Neo4jAcquisitionTimeout time.Duration `env:"NEO4J_ACQUISITION_TIMEOUT" envDefault:"5s"`
+	// Neo4jMaxRetryTime - how long the driver retries a transaction failing with a transient error, or losing its
+	// connection, before giving up (0 not to retry)
+	Neo4jMaxRetryTime time.Duration `env:"NEO4J_MAX_RETRY_TIME" envDefault:"10s"`
+
+	// BreakerFailures - number of consecutive neo4j failures opening the circuit breaker, the queries then failing fast
+	// with a 503 (0 to disable)
+	BreakerFailures int `env:"LEXNEO4J_BREAKER_FAILURES" envDefault:"5"`
+	// BreakerOpenTimeout - how long the circuit breaker stays open before letting a trial query through
+	BreakerOpenTimeout time.Duration `env:"LEXNEO4J_BREAKER_OPEN_TIMEOUT" envDefault:"10s"`
+
+	// HealthTimeout - timeout of the neo4j connectivity check of /health/ready, after which neo4j is reported unreachable
+	HealthTimeout time.Duration `env:"LEXNEO4J_HEALTH_TIMEOUT" envDefault:"2s"`
+	// HealthCacheTTL - how long the result of the neo4j connectivity check of /health/ready is cached
+	HealthCacheTTL time.Duration `env:"LEXNEO4J_HEALTH_CACHE_TTL" envDefault:"5s"`
+
+	// ShutdownTimeout - how long the shutdown waits for the neo4j transactions in flight before closing the driver
+	ShutdownTimeout time.Duration `env:"LEXNEO4J_SHUTDOWN_TIMEOUT" envDefault:"30s"`
+
+	// CypherMaxRows - maximum number of rows returned by each query of a /cypher command, the extra rows being dropped (0 to disable)
+	CypherMaxRows int `env:"LEXNEO4J_CYPHER_MAX_ROWS" envDefault:"0"`
+	// CypherRegexEnabled - to allow regular expressions (=~) in /cypher commands, as they can be expensive on large graphs
+	CypherRegexEnabled bool `env:"LEXNEO4J_CYPHER_REGEX_ENABLED" envDefault:"true"`
+	// CypherTimeout - default timeout of a /cypher command, after which neo4j aborts it and a 504 is returned
+	CypherTimeout time.Duration `env:"LEXNEO4J_CYPHER_TIMEOUT" envDefault:"30s"`
+	// CypherMaxTimeout - maximum timeout of a /cypher command, capping the timeoutMs asked by the clients
+	CypherMaxTimeout time.Duration `env:"LEXNEO4J_CYPHER_MAX_TIMEOUT" envDefault:"2m"`
+	// CypherMaxEstimatedRows - maximum number of rows estimated by an EXPLAIN of a /cypher command before running it,
+	// the commands above being rejected with a 422 (0 to disable, as it costs an additional round trip to neo4j)
+	CypherMaxEstimatedRows int `env:"LEXNEO4J_CYPHER_MAX_ESTIMATED_ROWS" envDefault:"0"`
+
+	// ComplexityMaxScore - maximum estimated cost of a /cypher command, the commands above being rejected with a 422
+	// (negative to disable)
+	ComplexityMaxScore int `env:"LEXNEO4J_COMPLEXITY_MAX_SCORE" envDefault:"100"`
+	// ComplexityMaxPatterns - maximum number of patterns of a /cypher command (negative for no maximum)
+	ComplexityMaxPatterns int `env:"LEXNEO4J_COMPLEXITY_MAX_PATTERNS" envDefault:"-1"`
+	// ComplexityMaxUnlabeledNodes - maximum number of unlabeled nodes of a /cypher command (negative for no maximum)
+	ComplexityMaxUnlabeledNodes int `env:"LEXNEO4J_COMPLEXITY_MAX_UNLABELED_NODES" envDefault:"-1"`
+	// ComplexityMaxUnboundedHops - maximum number of unbounded variable-length relationships (i.e. [*]) of a /cypher
+	// command (negative for no maximum)
+	ComplexityMaxUnboundedHops int `env:"LEXNEO4J_COMPLEXITY_MAX_UNBOUNDED_HOPS" envDefault:"-1"`
+	// ComplexityMaxCartesianProducts - maximum number of cartesian products of a /cypher command (negative for no maximum)
+	ComplexityMaxCartesianProducts int `env:"LEXNEO4J_COMPLEXITY_MAX_CARTESIAN_PRODUCTS" envDefault:"-1"`
+
+	// SlowQueryThreshold - duration of the /cypher commands above which they are logged as slow queries (0 to disable)
+	SlowQueryThreshold time.Duration `env:"LEXNEO4J_SLOW_QUERY_THRESHOLD" envDefault:"0"`
+	// SlowQueryExplain - to attach the plan of the slow queries to their log, explaining them again if needed
+	SlowQueryExplain bool `env:"LEXNEO4J_SLOW_QUERY_EXPLAIN" envDefault:"false"`
+	// SlowQueryFile - file receiving the slow queries as JSON lines, rotated every 100MB (the application log if empty)
+	SlowQueryFile string `env:"LEXNEO4J_SLOW_QUERY_FILE" envDefault:""`
+
+	// StatsMaxFingerprints - maximum number of query fingerprints tracked by /stats/queries, the queries of the other
+	// fingerprints being counted together
+	StatsMaxFingerprints int `env:"LEXNEO4J_STATS_MAX_FINGERPRINTS" envDefault:"1000"`
+
+	// PolicyFile - YAML or JSON file allowing / denying labels, relationship types and properties per caller role
+	// (no policy if empty)
+	PolicyFile string `env:"LEXNEO4J_POLICY_FILE" envDefault:"" reload:"true"`
+	// PolicyAnonymousRole - role of the callers that are not authenticated
+	PolicyAnonymousRole string `env:"LEXNEO4J_POLICY_ANONYMOUS_ROLE" envDefault:"anonymous"`
+	// AdminRoles - roles allowed to call the /admin endpoints via comma separated list (nobody if empty)
+	AdminRoles []string `env:"LEXNEO4J_ADMIN_ROLES" envDefault:"" envSeparator:","`
+
+	// RedactProperties - Label.property pairs (i.e. Person.born) redacted from the /cypher results via comma separated list
+	// (a relationship type can be used as the label)
+	RedactProperties []string `env:"LEXNEO4J_REDACT_PROPERTIES" envDefault:"" envSeparator:","`
+	// RedactMode - how the redacted properties are hidden
+	// Possible values: strip, mask
+	RedactMode string `env:"LEXNEO4J_REDACT_MODE" envDefault:"strip"`
+}
+
+// Config is the configuration of the app
+var Config Configuration
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/env"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileEnv is the environment variable of the configuration file, unless set with the --config-file flag
+const configFileEnv = "LEXNEO4J_CONFIG_FILE"
+
+// CommandLineOptions are the command line options of the configuration, to add to the CommandLineOptionsGroups of
+// the api in configure_lexneo4j.go. The configuration being read before the flags are parsed, --config-file is read
+// from the arguments directly.
+var CommandLineOptions = struct {
+	ConfigFile string `long:"config-file" description:"YAML configuration file, layered under the environment variables (or LEXNEO4J_CONFIG_FILE)"`
+}{}
+
+// The sources of a setting
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+)
+
+// configFile is the path of the configuration file, if any
+var configFile string
+
+// sources are the sources of the settings of Config, per environment variable
+var sources map[string]string
+
+// setting is a field of the configuration
+type setting struct {
+	// env is the environment variable of the setting, its key in the configuration file being fileKey(env)
+	env        string
+	separator  string
+	secret     bool
+	reloadable bool
+	value      reflect.Value
+}
+
+// settings returns the settings of a configuration, in the order of the struct
+func settings(c *Configuration) []setting {
+	settings := []setting{}
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		separator := field.Tag.Get("envSeparator")
+		if separator == "" {
+			separator = ","
+		}
+		settings = append(settings, setting{
+			env:        name,
+			separator:  separator,
+			secret:     field.Tag.Get("secret") == "true",
+			reloadable: field.Tag.Get("reload") == "true",
+			value:      v.Field(i),
+		})
+	}
+	return settings
+}
+
+// fileKey returns the key of a setting in the configuration file: its environment variable in lower case, without
+// the LEXNEO4J_ prefix (i.e. rate_limit_rate for LEXNEO4J_RATE_LIMIT_RATE, or neo4j_url for NEO4J_URL)
+func fileKey(env string) string {
+	return strings.ToLower(strings.TrimPrefix(env, "LEXNEO4J_"))
+}
+
+// configFilePath returns the path of the configuration file, from the --config-file flag or else from
+// LEXNEO4J_CONFIG_FILE
+func configFilePath(args []string) string {
+	for i, arg := range args {
+		if arg == "--config-file" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if path, ok := strings.CutPrefix(arg, "--config-file="); ok {
+			return path
+		}
+	}
+	return os.Getenv(configFileEnv)
+}
+
+// load reads the configuration from the environment variables, layered over the configuration file at path (if not
+// empty), and returns it with the source of each setting
+func load(path string) (Configuration, map[string]string, error) {
+	var c Configuration
+	if err := env.Parse(&c); err != nil {
+		return c, nil, err
+	}
+
+	values := map[string]interface{}{}
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return c, nil, fmt.Errorf("cannot read the configuration file: %w", err)
+		}
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			return c, nil, fmt.Errorf("cannot parse the configuration file %s: %w", path, err)
+		}
+	}
+
+	errs := []error{}
+	sources := map[string]string{}
+	known := map[string]bool{}
+	for _, s := range settings(&c) {
+		key := fileKey(s.env)
+		known[key] = true
+		value, inFile := values[key]
+		switch {
+		case hasEnv(s.env):
+			sources[s.env] = SourceEnv
+		case inFile:
+			sources[s.env] = SourceFile
+			if err := set(s, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		default:
+			sources[s.env] = SourceDefault
+		}
+	}
+	unknown := []string{}
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown setting", key))
+	}
+	if len(errs) > 0 {
+		return c, nil, fmt.Errorf("invalid configuration file %s:\n%w", path, errors.Join(errs...))
+	}
+	return c, sources, nil
+}
+
+// hasEnv returns true if the environment variable is set, even to an empty value
+func hasEnv(name string) bool {
+	_, ok := os.LookupEnv(name)
+	return ok
+}
+
+// set sets a setting to a value of the configuration file. A list can be a YAML sequence, or a string like in the
+// environment variable.
+func set(s setting, value interface{}) error {
+	if s.value.Kind() == reflect.Slice {
+		items := []string{}
+		switch value := value.(type) {
+		case []interface{}:
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+		case string:
+			if value != "" {
+				items = strings.Split(value, s.separator)
+			}
+		default:
+			return fmt.Errorf("expected a list, got %v", value)
+		}
+		s.value.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return fmt.Errorf("expected a single value, got %v", value)
+	}
+	str := fmt.Sprint(value)
+	switch s.value.Interface().(type) {
+	case string:
+		s.value.SetString(str)
+	case time.Duration:
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case int:
+		i, err := strconv.Atoi(str)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %v", value)
+		}
+		s.value.SetInt(int64(i))
+	case float64:
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %v", value)
+		}
+		s.value.SetFloat(f)
+	case bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return fmt.Errorf("expected a boolean, got %v", value)
+		}
+		s.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", s.value.Type())
+	}
+	return nil
+}
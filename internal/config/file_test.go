@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+// withConfig restores the configuration once the test is done
+func withConfig(t *testing.T, path string) {
+	savedConfig, savedSources, savedFile, savedHooks := Config, sources, configFile, reloadHooks
+	t.Cleanup(func() {
+		Config, sources, configFile, reloadHooks = savedConfig, savedSources, savedFile, savedHooks
+	})
+	configFile = path
+	var err error
+	Config, sources, err = load(path)
+	assert.Nil(t, err)
+}
+
+func effectiveSetting(t *testing.T, name string) Setting {
+	_, effective := Effective()
+	for _, s := range effective {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no setting %s", name)
+	return Setting{}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lexneo4j.yaml")
+	writeFile(t, path, `
+port: 18080
+neo4j_url: bolt://neo4j:7687
+neo4j_password: secret
+cypher_timeout: 10s
+rate_limit_rate: 2.5
+metrics_enabled: false
+auth_exempt_urls: [/api/v1/health, /metrics]
+rate_limit_concurrency_urls: /api/v1/cypher,/api/v1/movies
+`)
+	t.Setenv("NEO4J_URL", "neo4j://localhost:7687")
+
+	c, sources, err := load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 18080, c.Port)
+	// the environment variables are layered over the file
+	assert.Equal(t, "neo4j://localhost:7687", c.Neo4jURL)
+	assert.Equal(t, "secret", c.Neo4jPassword)
+	assert.Equal(t, 10*time.Second, c.CypherTimeout)
+	assert.Equal(t, 2.5, c.RateLimitRate)
+	assert.False(t, c.MetricsEnabled)
+	assert.Equal(t, []string{"/api/v1/health", "/metrics"}, c.AuthExemptURLs)
+	assert.Equal(t, []string{"/api/v1/cypher", "/api/v1/movies"}, c.RateLimitConcurrencyURLs)
+	assert.Equal(t, SourceFile, sources["PORT"])
+	assert.Equal(t, SourceEnv, sources["NEO4J_URL"])
+	assert.Equal(t, SourceDefault, sources["NEO4J_USERNAME"])
+
+	writeFile(t, path, `
+port: eighteen
+cypher_timeout: [10s]
+unknown_setting: true
+`)
+	_, _, err = load(path)
+	assert.NotNil(t, err)
+	assert.Equal(t, `invalid configuration file `+path+`:
+port: expected an integer, got eighteen
+cypher_timeout: expected a single value, got [10s]
+unknown_setting: unknown setting`, err.Error())
+
+	_, _, err = load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NotNil(t, err)
+}
+
+func TestConfigFilePath(t *testing.T) {
+	t.Setenv(configFileEnv, "/etc/lexneo4j/env.yaml")
+	assert.Equal(t, "/etc/lexneo4j/flag.yaml", configFilePath([]string{"--port", "18000", "--config-file", "/etc/lexneo4j/flag.yaml"}))
+	assert.Equal(t, "/etc/lexneo4j/flag.yaml", configFilePath([]string{"--config-file=/etc/lexneo4j/flag.yaml"}))
+	assert.Equal(t, "/etc/lexneo4j/env.yaml", configFilePath([]string{"--port", "18000"}))
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lexneo4j.yaml")
+	writeFile(t, path, `
+rate_limit_rate: 1
+neo4j_password: secret
+`)
+	withConfig(t, path)
+	reloads := 0
+	reloadHooks = nil
+	OnReload("test", func() error {
+		reloads++
+		return nil
+	})
+
+	writeFile(t, path, `
+rate_limit_rate: 5
+neo4j_password: secret
+cypher_max_rows: 10
+`)
+	assert.Nil(t, Reload())
+	assert.Equal(t, 1, reloads)
+	assert.Equal(t, float64(5), Config.RateLimitRate)
+	// only applied after a restart
+	assert.Equal(t, 0, Config.CypherMaxRows)
+
+	s := effectiveSetting(t, "LEXNEO4J_RATE_LIMIT_RATE")
+	assert.Equal(t, Setting{Name: "LEXNEO4J_RATE_LIMIT_RATE", Key: "rate_limit_rate", Value: float64(5), Source: SourceFile, Reloadable: true}, s)
+	assert.Equal(t, redacted, effectiveSetting(t, "NEO4J_PASSWORD").Value)
+	assert.Equal(t, "", effectiveSetting(t, "LEXNEO4J_AUTH_JWT_SECRET").Value)
+	assert.Equal(t, "30s", effectiveSetting(t, "LEXNEO4J_CYPHER_TIMEOUT").Value)
+
+	// an invalid configuration is ignored
+	writeFile(t, path, `
+rate_limit_rate: 7
+neo4j_url: http://localhost
+`)
+	assert.NotNil(t, Reload())
+	assert.Equal(t, 1, reloads)
+	assert.Equal(t, float64(5), Config.RateLimitRate)
+
+	OnReload("failing", func() error {
+		return errors.New("invalid policy")
+	})
+	writeFile(t, path, `rate_limit_rate: 7`)
+	assert.Equal(t, "cannot reload the failing: invalid policy", Reload().Error())
+	assert.Equal(t, 2, reloads)
+}
+
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lexneo4j.yaml")
+	writeFile(t, path, `rate_limit_burst: 1`)
+	withConfig(t, path)
+	reloadHooks = nil
+	savedDebounce := watchDebounce
+	watchDebounce = 10 * time.Millisecond
+	defer func() { watchDebounce = savedDebounce }()
+
+	watchFiles()
+	defer stopWatching()
+
+	// replaced by a rename, like the editors do
+	writeFile(t, filepath.Join(dir, "lexneo4j.yaml.tmp"), `rate_limit_burst: 3`)
+	assert.Nil(t, os.Rename(filepath.Join(dir, "lexneo4j.yaml.tmp"), path))
+	assert.Eventually(t, func() bool {
+		reloadMu.RLock()
+		defer reloadMu.RUnlock()
+		return Config.RateLimitBurst == 3
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -1,19 +1,22 @@
 package config
 
 import (
+	"context"
+	"crypto"
+	"fmt"
 	"net/http"
+	"sync/atomic"
 
 	negronilogrus "github.com/meatballhat/negroni-logrus"
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/nzin/lexneo4j/internal/metrics"
+	"github.com/nzin/lexneo4j/internal/ratelimit"
+	"github.com/nzin/lexneo4j/internal/tracing"
 	"github.com/phyber/negroni-gzip/gzip"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/negroni"
 )
 
-// ServerShutdown is a callback function that will be called when
-// we tear down the golang-skeleton server
-func ServerShutdown() {
-}
-
 // SetupGlobalMiddleware setup the global middleware
 func SetupGlobalMiddleware(handler http.Handler) http.Handler {
 	n := negroni.New()
@@ -34,9 +37,41 @@ func SetupGlobalMiddleware(handler http.Handler) http.Handler {
 
 	n.Use(setupRecoveryMiddleware())
 
+	if Config.MetricsEnabled {
+		n.Use(metrics.NewMiddleware(Config.MetricsPath))
+	}
+
+	// the authentication and the limits are replaced when the configuration is reloaded
+	authentication := &swappable{}
+	authHandler, err := setupAuthMiddleware()
+	if err != nil {
+		logrus.WithField("err", err).Fatal("invalid authentication configuration")
+	}
+	authentication.set(authHandler)
+	n.Use(authentication)
+	OnReload("authentication", func() error {
+		authHandler, err := setupAuthMiddleware()
+		if err != nil {
+			return err
+		}
+		authentication.set(authHandler)
+		return nil
+	})
+
+	// after the authentication, to limit the callers by identity
+	limits := &swappable{}
+	limits.set(setupRateLimitMiddleware())
+	n.Use(limits)
+	OnReload("rate limits", func() error {
+		limits.set(setupRateLimitMiddleware())
+		return nil
+	})
+
 	n.UseHandler(handler)
+	watchFiles()
 
-	return n
+	// around the whole chain, so that the span of a request covers all the middlewares
+	return setupTracing(n)
 }
 
 type recoveryLogger struct{}
@@ -54,3 +89,144 @@ func setupRecoveryMiddleware() *negroni.Recovery {
 	r.Logger = &recoveryLogger{}
 	return r
 }
+
+// swappable is a middleware replaced when the configuration is reloaded, i.e. with the new API keys
+type swappable struct {
+	handler atomic.Pointer[negroni.Handler]
+}
+
+// set replaces the middleware, nil letting all the requests through
+func (s *swappable) set(handler negroni.Handler) {
+	s.handler.Store(&handler)
+}
+
+func (s *swappable) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if handler := s.handler.Load(); handler != nil && *handler != nil {
+		(*handler).ServeHTTP(rw, r, next)
+		return
+	}
+	next(rw, r)
+}
+
+// setupAuthMiddleware returns the authentication middleware, or nil if no API key, JWT nor client certificate is configured
+func setupAuthMiddleware() (negroni.Handler, error) {
+	authenticators := []auth.Authenticator{}
+	apiKeys, err := setupAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	if apiKeys != nil {
+		authenticators = append(authenticators, apiKeys)
+	}
+	jwt, err := setupJWT()
+	if err != nil {
+		return nil, err
+	}
+	if jwt != nil {
+		authenticators = append(authenticators, jwt)
+	}
+	clientCerts, err := setupClientCerts()
+	if err != nil {
+		return nil, err
+	}
+	if clientCerts != nil {
+		authenticators = append(authenticators, clientCerts)
+	}
+
+	authentication := auth.NewMiddleware(authenticators, Config.AuthExemptURLs)
+	if authentication == nil {
+		logrus.Warn("no API key, JWT nor client certificate configured, the API is not authenticated")
+		return nil, nil
+	}
+	return authentication, nil
+}
+
+// setupAPIKeys returns the API keys allowed to call the API, or nil if no API key is configured
+func setupAPIKeys() (*auth.APIKeys, error) {
+	keys, err := auth.ParseAPIKeys(Config.AuthAPIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API keys: %w", err)
+	}
+	if Config.AuthAPIKeysFile != "" {
+		fileKeys, err := auth.LoadAPIKeysFile(Config.AuthAPIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the API keys file %s: %w", Config.AuthAPIKeysFile, err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	apiKeys, err := auth.NewAPIKeys(keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API keys: %w", err)
+	}
+	return apiKeys, nil
+}
+
+// setupJWT returns the JWT bearer authentication, or nil if neither a secret nor a JWKS file is configured
+func setupJWT() (*auth.JWT, error) {
+	var keys map[string]crypto.PublicKey
+	if Config.AuthJWTJWKSFile != "" {
+		var err error
+		keys, err = auth.LoadJWKSFile(Config.AuthJWTJWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the JWKS file %s: %w", Config.AuthJWTJWKSFile, err)
+		}
+	}
+
+	return auth.NewJWT(auth.JWTConfig{
+		Secret:        Config.AuthJWTSecret,
+		Keys:          keys,
+		Issuer:        Config.AuthJWTIssuer,
+		Audience:      Config.AuthJWTAudience,
+		NameClaim:     Config.AuthJWTNameClaim,
+		RolesClaim:    Config.AuthJWTRolesClaim,
+		TenantClaim:   Config.AuthJWTTenantClaim,
+		AllowNoTenant: Config.AuthJWTAllowNoTenant,
+	}), nil
+}
+
+// setupClientCerts returns the client certificate authentication, or nil if it is not enabled
+func setupClientCerts() (*auth.ClientCerts, error) {
+	if !Config.AuthClientCertEnabled {
+		return nil, nil
+	}
+	var subjects []auth.ClientCert
+	if Config.AuthClientCertsFile != "" {
+		var err error
+		subjects, err = auth.LoadClientCertsFile(Config.AuthClientCertsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificates file %s: %w", Config.AuthClientCertsFile, err)
+		}
+	}
+	return auth.NewClientCerts(subjects)
+}
+
+// setupRateLimitMiddleware returns the rate and concurrency limiting middleware, or nil if there are no limits
+func setupRateLimitMiddleware() negroni.Handler {
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Rate:                   Config.RateLimitRate,
+		Burst:                  Config.RateLimitBurst,
+		MaxConcurrentPerCaller: Config.RateLimitMaxConcurrentPerCaller,
+		MaxConcurrent:          Config.RateLimitMaxConcurrent,
+		ConcurrencyURLs:        Config.RateLimitConcurrencyURLs,
+		ExemptURLs:             Config.RateLimitExemptURLs,
+	})
+	if limiter == nil {
+		return nil
+	}
+	return limiter
+}
+
+// setupTracing sets the export of the OpenTelemetry spans up, and starts a span per request
+func setupTracing(handler http.Handler) http.Handler {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Enabled:     Config.TracingEnabled,
+		ServiceName: Config.TracingServiceName,
+		SampleRatio: Config.TracingSampleRatio,
+	})
+	if err != nil {
+		logrus.WithField("err", err).Fatalf("invalid tracing configuration")
+	}
+	shutdownTracing = shutdown
+	return tracing.Handler(handler)
+}
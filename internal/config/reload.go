@@ -0,0 +1,218 @@
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadMu guards the reloadable settings of Config, replaced on reload
+var reloadMu sync.RWMutex
+
+type reloadHook struct {
+	name   string
+	reload func() error
+}
+
+var reloadHooks []reloadHook
+
+// watchDebounce is the delay after the last change of a watched file before reloading, the editors writing a file
+// in several steps
+var watchDebounce = 500 * time.Millisecond
+
+// stopWatching stops watching the files, waiting for a reload in progress
+var stopWatching = func() {}
+
+// OnReload registers a function applying the reloadable settings (i.e. rebuilding the rate limiter), called after
+// each reload of the configuration, in the order of registration. If it fails, it should keep the previous settings.
+func OnReload(name string, reload func() error) {
+	reloadHooks = append(reloadHooks, reloadHook{name: name, reload: reload})
+}
+
+// Reload reads the configuration again, and applies its reloadable settings. The other settings that changed are only
+// applied after a restart. An invalid configuration is ignored, the current one being kept.
+func Reload() error {
+	next, nextSources, err := load(configFile)
+	if err == nil {
+		err = validate(&next)
+	}
+	if err != nil {
+		return err
+	}
+
+	reloadMu.Lock()
+	current := settings(&Config)
+	for i, s := range settings(&next) {
+		if reflect.DeepEqual(current[i].value.Interface(), s.value.Interface()) {
+			continue
+		}
+		if !s.reloadable {
+			logrus.Warnf("%s changed, restart the server to apply it", s.env)
+			continue
+		}
+		current[i].value.Set(s.value)
+		sources[s.env] = nextSources[s.env]
+		logrus.Infof("%s reloaded", s.env)
+	}
+	reloadMu.Unlock()
+
+	setupLogrus()
+	errs := []error{}
+	for _, hook := range reloadHooks {
+		if err := hook.reload(); err != nil {
+			errs = append(errs, fmt.Errorf("cannot reload the %s: %w", hook.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// watchedFiles returns the files whose changes are hot reloaded: the configuration file, the policy file and the API
+// keys file
+func watchedFiles() []string {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+
+	files := []string{}
+	for _, file := range []string{configFile, Config.PolicyFile, Config.AuthAPIKeysFile} {
+		if file != "" {
+			files = append(files, filepath.Clean(file))
+		}
+	}
+	return files
+}
+
+// watchFiles reloads the configuration when a watched file changes. The directories of the files are watched, to
+// follow the files replaced by a rename (i.e. by the editors, or in a kubernetes config map).
+func watchFiles() {
+	files := watchedFiles()
+	if len(files) == 0 {
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logrus.WithField("err", err).Error("cannot watch the configuration files, they are not hot reloaded")
+		return
+	}
+
+	dirs := map[string]bool{}
+	hashes := map[string][sha256.Size]byte{}
+	watch := func(files []string) {
+		for _, file := range files {
+			if _, ok := hashes[file]; !ok {
+				hashes[file] = hashFile(file)
+			}
+			if dir := filepath.Dir(file); !dirs[dir] {
+				if err := watcher.Add(dir); err != nil {
+					logrus.WithField("err", err).Errorf("cannot watch %s, it is not hot reloaded", file)
+					continue
+				}
+				dirs[dir] = true
+			}
+		}
+	}
+	watch(files)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var debounce <-chan time.Time
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				debounce = time.After(watchDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logrus.WithField("err", err).Warn("error watching the configuration files")
+			case <-debounce:
+				debounce = nil
+				if !filesChanged(hashes) {
+					continue
+				}
+				logrus.Info("reloading the configuration")
+				if err := Reload(); err != nil {
+					logrus.WithField("err", err).Error("failed to reload the configuration")
+				}
+				// the policy file or the API keys file may have been changed
+				watch(watchedFiles())
+			}
+		}
+	}()
+	stopWatching = func() {
+		watcher.Close()
+		<-done
+	}
+}
+
+// filesChanged returns true if the content of a watched file changed since the last call, updating their hashes
+func filesChanged(hashes map[string][sha256.Size]byte) bool {
+	changed := false
+	for file, hash := range hashes {
+		if current := hashFile(file); current != hash {
+			hashes[file] = current
+			changed = true
+		}
+	}
+	return changed
+}
+
+// hashFile returns the hash of the content of a file, the zero hash if it cannot be read
+func hashFile(path string) [sha256.Size]byte {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(content)
+}
+
+// redacted replaces the values of the secret settings
+const redacted = "<redacted>"
+
+// Setting is the effective value of a setting
+type Setting struct {
+	// Name is the environment variable of the setting
+	Name string
+	// Key is the key of the setting in the configuration file
+	Key        string
+	Value      interface{}
+	Source     string
+	Reloadable bool
+}
+
+// Effective returns the path of the configuration file (if any) and the effective settings, the secrets being
+// redacted
+func Effective() (string, []Setting) {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+
+	effective := []Setting{}
+	for _, s := range settings(&Config) {
+		value := s.value.Interface()
+		if s.secret && s.value.Len() > 0 {
+			value = redacted
+		}
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		effective = append(effective, Setting{
+			Name:       s.env,
+			Key:        fileKey(s.env),
+			Value:      value,
+			Source:     sources[s.env],
+			Reloadable: s.reloadable,
+		})
+	}
+	return configFile, effective
+}
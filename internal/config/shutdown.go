@@ -0,0 +1,64 @@
+package config
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Stopper is a component stopped on shutdown, i.e. the handlers holding the neo4j driver
+type Stopper interface {
+	// Drain stops accepting new work
+	Drain()
+	// Shutdown waits for the work in flight until ctx is done, then releases the resources
+	Shutdown(ctx context.Context) error
+}
+
+type namedStopper struct {
+	name string
+	Stopper
+}
+
+var stoppers []namedStopper
+
+// shutdownTracing flushes the spans not exported yet
+var shutdownTracing = func(context.Context) error { return nil }
+
+// OnShutdown registers a component to stop when the server shuts down, in the order of registration
+func OnShutdown(name string, s Stopper) {
+	stoppers = append(stoppers, namedStopper{name: name, Stopper: s})
+}
+
+// PreServerShutdown is a callback function that will be called when
+// the golang-skeleton server starts to shut down, before it stops accepting connections
+func PreServerShutdown() {
+	logrus.Info("shutting down: draining the requests in flight")
+	for _, s := range stoppers {
+		s.Drain()
+	}
+}
+
+// ServerShutdown is a callback function that will be called when
+// we tear down the golang-skeleton server. The registered components get up to ShutdownTimeout to finish their work.
+func ServerShutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), Config.ShutdownTimeout)
+	defer cancel()
+	stopWatching()
+
+	for _, s := range stoppers {
+		// in case PreServerShutdown was not called
+		s.Drain()
+	}
+	for _, s := range stoppers {
+		logrus.Infof("shutting down: stopping %s", s.name)
+		if err := s.Shutdown(ctx); err != nil {
+			logrus.WithField("err", err).Errorf("failed to stop %s", s.name)
+		}
+	}
+
+	// the spans of the last requests are flushed, even if the timeout is reached
+	if err := shutdownTracing(context.Background()); err != nil {
+		logrus.WithField("err", err).Error("failed to flush the spans")
+	}
+	logrus.Info("shutdown complete")
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"crypto/tls"
+
+	"github.com/nzin/lexneo4j/internal/tlsconfig"
+	"github.com/sirupsen/logrus"
+)
+
+// ConfigureTLS sets the certificate of the server and the verification of the client certificates up. It is called
+// from the configureTLS of configure_lexneo4j.go, after the --tls-* flags of the server are applied.
+func ConfigureTLS(tlsConfig *tls.Config) {
+	server := tlsconfig.Server{
+		CertFile:     Config.TLSCertFile,
+		KeyFile:      Config.TLSKeyFile,
+		ClientCAFile: Config.TLSClientCAFile,
+		ClientAuth:   Config.TLSClientAuth,
+	}
+	if err := server.Apply(tlsConfig); err != nil {
+		logrus.WithField("err", err).Fatal("invalid TLS configuration")
+	}
+}
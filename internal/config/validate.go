@@ -0,0 +1,93 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/nzin/lexneo4j/internal/tlsconfig"
+	"github.com/sirupsen/logrus"
+)
+
+// neo4jSchemes are the URL schemes supported by the neo4j driver
+var neo4jSchemes = map[string]bool{
+	"bolt": true, "bolt+s": true, "bolt+ssc": true,
+	"neo4j": true, "neo4j+s": true, "neo4j+ssc": true,
+}
+
+// Validate checks the configuration, reporting all the problems at once
+func Validate() error {
+	return validate(&Config)
+}
+
+func validate(c *Configuration) error {
+	errs := []error{}
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: %d is not a port between 1 and 65535", c.Port))
+	}
+	if _, err := logrus.ParseLevel(c.LogrusLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LEXNEO4J_LOGRUS_LEVEL: %v", err))
+	}
+	if c.LogrusFormat != "text" && c.LogrusFormat != "json" {
+		errs = append(errs, fmt.Errorf("LEXNEO4J_LOGRUS_FORMAT: unexpected format %q, should be one of: text, json", c.LogrusFormat))
+	}
+
+	if u, err := url.Parse(c.Neo4jURL); err != nil {
+		errs = append(errs, fmt.Errorf("NEO4J_URL: %v", err))
+	} else if !neo4jSchemes[u.Scheme] {
+		errs = append(errs, fmt.Errorf("NEO4J_URL: unsupported scheme %q, should be one of: bolt, neo4j (optionally with +s or +ssc)", u.Scheme))
+	} else if u.Hostname() == "" {
+		errs = append(errs, fmt.Errorf("NEO4J_URL: missing host in %q", c.Neo4jURL))
+	} else if c.Neo4jCAFile != "" && u.Scheme != "bolt+s" && u.Scheme != "neo4j+s" {
+		errs = append(errs, fmt.Errorf("NEO4J_CA_FILE: only used with the bolt+s and neo4j+s schemes, not %s", u.Scheme))
+	}
+	if c.Neo4jUsername == "" {
+		errs = append(errs, errors.New("NEO4J_USERNAME: missing username"))
+	}
+	if c.Neo4jPassword == "" {
+		errs = append(errs, errors.New("NEO4J_PASSWORD: missing password"))
+	}
+	if c.Neo4jConnectTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("NEO4J_CONNECT_TIMEOUT: %v is not positive", c.Neo4jConnectTimeout))
+	}
+	if c.Neo4jAcquisitionTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("NEO4J_ACQUISITION_TIMEOUT: %v is not positive", c.Neo4jAcquisitionTimeout))
+	}
+	if c.Neo4jMaxRetryTime < 0 {
+		errs = append(errs, fmt.Errorf("NEO4J_MAX_RETRY_TIME: %v is negative", c.Neo4jMaxRetryTime))
+	}
+	if c.BreakerFailures < 0 {
+		errs = append(errs, fmt.Errorf("LEXNEO4J_BREAKER_FAILURES: %d is negative", c.BreakerFailures))
+	} else if c.BreakerFailures > 0 && c.BreakerOpenTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("LEXNEO4J_BREAKER_OPEN_TIMEOUT: %v is not positive", c.BreakerOpenTimeout))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("LEXNEO4J_TLS_CERT_FILE and LEXNEO4J_TLS_KEY_FILE: both are expected"))
+	}
+	switch c.TLSClientAuth {
+	case tlsconfig.ClientAuthNone:
+	case tlsconfig.ClientAuthOptional, tlsconfig.ClientAuthRequire:
+		if c.TLSClientCAFile == "" {
+			errs = append(errs, fmt.Errorf("LEXNEO4J_TLS_CLIENT_CA_FILE: missing file to verify the client certificates"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("LEXNEO4J_TLS_CLIENT_AUTH: unexpected mode %q, should be one of: none, optional, require", c.TLSClientAuth))
+	}
+	if c.AuthClientCertEnabled && c.TLSClientAuth == tlsconfig.ClientAuthNone {
+		errs = append(errs, errors.New("LEXNEO4J_AUTH_CLIENT_CERT_ENABLED: the client certificates are not verified (LEXNEO4J_TLS_CLIENT_AUTH is none)"))
+	}
+
+	if c.CypherTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("LEXNEO4J_CYPHER_TIMEOUT: %v is not positive", c.CypherTimeout))
+	}
+	if c.CypherMaxTimeout < c.CypherTimeout {
+		errs = append(errs, fmt.Errorf("LEXNEO4J_CYPHER_MAX_TIMEOUT: %v is below LEXNEO4J_CYPHER_TIMEOUT (%v)", c.CypherMaxTimeout, c.CypherTimeout))
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("LEXNEO4J_TRACING_SAMPLE_RATIO: %v is not between 0 and 1", c.TracingSampleRatio))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/nzin/lexneo4j/internal/tlsconfig/tlstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Validate())
+
+	saved := Config
+	defer func() { Config = saved }()
+
+	Config.Port = 70000
+	Config.LogrusLevel = "verbose"
+	Config.Neo4jURL = "http://localhost:7474"
+	Config.Neo4jPassword = ""
+	Config.BreakerOpenTimeout = 0
+	Config.TracingSampleRatio = 2
+	err := Validate()
+	assert.NotNil(t, err)
+	assert.Equal(t, `PORT: 70000 is not a port between 1 and 65535
+LEXNEO4J_LOGRUS_LEVEL: not a valid logrus Level: "verbose"
+NEO4J_URL: unsupported scheme "http", should be one of: bolt, neo4j (optionally with +s or +ssc)
+NEO4J_PASSWORD: missing password
+LEXNEO4J_BREAKER_OPEN_TIMEOUT: 0s is not positive
+LEXNEO4J_TRACING_SAMPLE_RATIO: 2 is not between 0 and 1`, err.Error())
+
+	Config = saved
+	Config.Neo4jURL = "bolt+s://:7687"
+	assert.Equal(t, `NEO4J_URL: missing host in "bolt+s://:7687"`, Validate().Error())
+}
+
+func TestValidateTLS(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+
+	Config.TLSCertFile = "server.crt"
+	Config.TLSClientAuth = "always"
+	Config.AuthClientCertEnabled = true
+	Config.Neo4jCAFile = "ca.crt"
+	assert.Equal(t, `NEO4J_CA_FILE: only used with the bolt+s and neo4j+s schemes, not neo4j
+LEXNEO4J_TLS_CERT_FILE and LEXNEO4J_TLS_KEY_FILE: both are expected
+LEXNEO4J_TLS_CLIENT_AUTH: unexpected mode "always", should be one of: none, optional, require`, Validate().Error())
+
+	Config.TLSClientAuth = "none"
+	Config.TLSKeyFile = "server.key"
+	Config.Neo4jURL = "neo4j+s://neo4j.example.com"
+	assert.Equal(t, `LEXNEO4J_AUTH_CLIENT_CERT_ENABLED: the client certificates are not verified (LEXNEO4J_TLS_CLIENT_AUTH is none)`, Validate().Error())
+
+	Config.TLSClientAuth = "require"
+	assert.Equal(t, `LEXNEO4J_TLS_CLIENT_CA_FILE: missing file to verify the client certificates`, Validate().Error())
+	Config.TLSClientCAFile = "ca.crt"
+	assert.Nil(t, Validate())
+}
+
+func TestConfigureTLS(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+
+	ca := tlstest.NewCA(t, "ca")
+	server := ca.Server("server")
+	Config.TLSCertFile, Config.TLSKeyFile = server.CertFile, server.KeyFile
+	Config.TLSClientAuth, Config.TLSClientCAFile = "optional", ca.CertFile
+
+	tlsConfig := &tls.Config{}
+	ConfigureTLS(tlsConfig)
+	assert.Equal(t, 1, len(tlsConfig.Certificates))
+	assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+	assert.True(t, tlsConfig.ClientCAs.Equal(ca.Pool()))
+}
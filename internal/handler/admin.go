@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/nzin/lexneo4j/internal/config"
+	"github.com/nzin/lexneo4j/internal/policy"
+	"github.com/nzin/lexneo4j/internal/util"
+	"github.com/nzin/lexneo4j/swagger_gen/models"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/sirupsen/logrus"
+)
+
+func (c *crud) GetConfig(params app.GetConfigParams) middleware.Responder {
+	if !isAdmin(params.HTTPRequest) {
+		return app.NewGetConfigForbidden().WithPayload(ErrorMessage("an admin role is required"))
+	}
+
+	file, effective := config.Effective()
+	settings := []*models.ConfigSetting{}
+	for _, s := range effective {
+		settings = append(settings, &models.ConfigSetting{
+			Name:       util.StringPtr(s.Name),
+			Key:        util.StringPtr(s.Key),
+			Value:      s.Value,
+			Source:     util.StringPtr(s.Source),
+			Reloadable: s.Reloadable,
+		})
+	}
+	return app.NewGetConfigOK().WithPayload(&models.AdminConfig{File: file, Settings: settings})
+}
+
+// isAdmin returns true if the caller has one of the admin roles. Nobody is an admin if no admin role is configured.
+func isAdmin(r *http.Request) bool {
+	for _, role := range callerRoles(r) {
+		for _, admin := range config.Config.AdminRoles {
+			if role == admin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadPolicy loads the access policy file, if any
+func loadPolicy() (*policy.Policy, error) {
+	if config.Config.PolicyFile == "" {
+		return nil, nil
+	}
+	return policy.LoadFile(config.Config.PolicyFile)
+}
+
+// ReloadPolicy loads the access policy file again, keeping the current policy if it is invalid
+func (c *crud) ReloadPolicy() error {
+	accessPolicy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+	c.policy.Store(accessPolicy)
+	logrus.Info("access policy reloaded")
+	return nil
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/nzin/lexneo4j/internal/config"
+	"github.com/nzin/lexneo4j/internal/policy"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetConfig(t *testing.T) {
+	saved := config.Config.AdminRoles
+	defer func() { config.Config.AdminRoles = saved }()
+
+	getConfig := func(identity *auth.Identity) interface{} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+		if identity != nil {
+			r = r.WithContext(auth.WithIdentity(r.Context(), identity))
+		}
+		return (&crud{}).GetConfig(app.GetConfigParams{HTTPRequest: r})
+	}
+
+	// nobody is an admin without any admin role
+	config.Config.AdminRoles = nil
+	_, forbidden := getConfig(&auth.Identity{Name: "bob", Roles: []string{"admin"}}).(*app.GetConfigForbidden)
+	assert.True(t, forbidden)
+
+	config.Config.AdminRoles = []string{"admin"}
+	_, forbidden = getConfig(nil).(*app.GetConfigForbidden)
+	assert.True(t, forbidden)
+	_, forbidden = getConfig(&auth.Identity{Name: "alice", Roles: []string{"reader"}}).(*app.GetConfigForbidden)
+	assert.True(t, forbidden)
+
+	ok, isOK := getConfig(&auth.Identity{Name: "bob", Roles: []string{"reader", "admin"}}).(*app.GetConfigOK)
+	assert.True(t, isOK)
+	values := map[string]interface{}{}
+	for _, s := range ok.Payload.Settings {
+		values[*s.Name] = s.Value
+	}
+	assert.Equal(t, "<redacted>", values["NEO4J_PASSWORD"])
+	assert.Equal(t, "neo4j", values["NEO4J_USERNAME"])
+	assert.Equal(t, []string{"admin"}, values["LEXNEO4J_ADMIN_ROLES"])
+}
+
+func TestReloadPolicy(t *testing.T) {
+	saved := config.Config.PolicyFile
+	defer func() { config.Config.PolicyFile = saved }()
+	config.Config.PolicyFile = filepath.Join(t.TempDir(), "policy.yaml")
+
+	c := &crud{}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/cypher", nil)
+	assert.Nil(t, os.WriteFile(config.Config.PolicyFile, []byte("roles: {anonymous: {labels: {deny: [Movie]}}}"), 0600))
+	assert.Nil(t, c.ReloadPolicy())
+	_, err := c.prepareQuery(r, "MATCH (m:Movie) RETURN m.title")
+	assert.IsType(t, &policy.Denial{}, err)
+
+	// an invalid policy is ignored
+	assert.Nil(t, os.WriteFile(config.Config.PolicyFile, []byte("roles: ["), 0600))
+	assert.NotNil(t, c.ReloadPolicy())
+	_, err = c.prepareQuery(r, "MATCH (m:Movie) RETURN m.title")
+	assert.IsType(t, &policy.Denial{}, err)
+
+	assert.Nil(t, os.WriteFile(config.Config.PolicyFile, []byte("roles: {anonymous: {labels: {deny: [Person]}}}"), 0600))
+	assert.Nil(t, c.ReloadPolicy())
+	_, err = c.prepareQuery(r, "MATCH (m:Movie) RETURN m.title")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/breaker"
+	"github.com/nzin/lexneo4j/internal/config"
+	"github.com/nzin/lexneo4j/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// neo4jUnavailable is the message of the queries failing fast while the circuit breaker is open
+const neo4jUnavailable = "neo4j is unavailable, failing fast until it recovers"
+
+// newBreaker creates the circuit breaker around the neo4j calls, nil if disabled
+func newBreaker() *breaker.Breaker {
+	b := breaker.New(config.Config.BreakerFailures, config.Config.BreakerOpenTimeout, isNeo4jFailure)
+	if b == nil {
+		return nil
+	}
+	metrics.SetBreakerState(string(breaker.Closed))
+	b.OnChange(func(state breaker.State) {
+		metrics.SetBreakerState(string(state))
+		if state == breaker.Open {
+			logrus.Warnf("neo4j circuit breaker open, failing the queries fast for %v", config.Config.BreakerOpenTimeout)
+		} else {
+			logrus.Infof("neo4j circuit breaker %s", state)
+		}
+	})
+	return b
+}
+
+// isNeo4jFailure returns true if the error shows neo4j is unavailable or degraded: a lost connection, no connection
+// available in time, a transient or a database error (once the driver gave up retrying). The client errors (i.e. an
+// invalid query or a timeout asked by the client) and the canceled requests do not count.
+func isNeo4jFailure(err error) bool {
+	if errors.Is(err, context.Canceled) || isTimeout(err) {
+		return false
+	}
+	var connectivityErr *neo4j.ConnectivityError
+	var limitErr *neo4j.TransactionExecutionLimit
+	if errors.As(err, &connectivityErr) || errors.As(err, &limitErr) {
+		return true
+	}
+	var neo4jErr *neo4j.Neo4jError
+	if errors.As(err, &neo4jErr) {
+		classification := neo4jErr.Classification()
+		return classification == "TransientError" || classification == "DatabaseError"
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/breaker"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNeo4jFailure(t *testing.T) {
+	for _, err := range []error{
+		&neo4j.ConnectivityError{},
+		&neo4j.TransactionExecutionLimit{},
+		&neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable"},
+		&neo4j.Neo4jError{Code: "Neo.DatabaseError.General.UnknownError"},
+	} {
+		assert.True(t, isNeo4jFailure(err), "%T", err)
+	}
+
+	for _, err := range []error{
+		&neo4j.Neo4jError{Code: "Neo.ClientError.Statement.SyntaxError"},
+		&neo4j.Neo4jError{Code: "Neo.ClientError.Transaction.TransactionTimedOut"},
+		&neo4j.UsageError{Message: "invalid session"},
+		fmt.Errorf("reading: %w", context.DeadlineExceeded),
+		context.Canceled,
+		errors.New("no plan returned"),
+	} {
+		assert.False(t, isNeo4jFailure(err), "%v", err)
+	}
+}
+
+func TestBreakerOpen(t *testing.T) {
+	probe := func() (string, string, error) { return "Neo4j/4.4.5", "neo4j", nil }
+	c := &crud{
+		inflight:  newInflight(),
+		readiness: newReadiness(probe, time.Second, time.Minute),
+		breaker:   breaker.New(1, time.Minute, isNeo4jFailure),
+	}
+
+	ok, isOK := c.GetReady(health.GetReadyParams{}).(*health.GetReadyOK)
+	assert.True(t, isOK)
+	assert.Equal(t, "closed", ok.Payload.Breaker)
+
+	assert.Nil(t, c.breaker.Allow())
+	c.breaker.Done(&neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable"})
+
+	// fails fast, without any session on the (missing) driver
+	responder := c.ListMovies(app.ListMoviesParams{
+		HTTPRequest: httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil),
+	})
+	def, isDefault := responder.(*app.ListMoviesDefault)
+	assert.True(t, isDefault)
+	assert.Equal(t, neo4jUnavailable, *def.Payload.Message)
+
+	ok, isOK = c.GetReady(health.GetReadyParams{}).(*health.GetReadyOK)
+	assert.True(t, isOK)
+	assert.Equal(t, "open", ok.Payload.Breaker)
+}
+
+// panickingDriver opens sessions whose transactions panic, as reading an unexpected value would
+type panickingDriver struct {
+	neo4j.Driver
+}
+
+func (d *panickingDriver) NewSession(neo4j.SessionConfig) neo4j.Session {
+	return &panickingSession{}
+}
+
+type panickingSession struct {
+	neo4j.Session
+}
+
+func (s *panickingSession) ReadTransaction(neo4j.TransactionWork, ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	panic("interface conversion: interface {} is nil, not string")
+}
+
+func (s *panickingSession) Close() error {
+	return nil
+}
+
+func TestBreakerPanic(t *testing.T) {
+	c := &crud{
+		neo4jdriver: &panickingDriver{},
+		inflight:    newInflight(),
+		breaker:     breaker.New(1, 0, isNeo4jFailure),
+	}
+	c.breaker.Allow()
+	c.breaker.Done(&neo4j.ConnectivityError{})
+	assert.Equal(t, breaker.HalfOpen, c.breaker.State())
+
+	// the trial call panics, the next ones are not failing fast forever
+	assert.Panics(t, func() {
+		c.ListMovies(app.ListMoviesParams{
+			HTTPRequest: httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil),
+		})
+	})
+	assert.Nil(t, c.breaker.Allow())
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// connectInitialBackoff is the wait after the first failed attempt to connect to neo4j, doubled after each attempt
+	connectInitialBackoff = 500 * time.Millisecond
+	// connectMaxBackoff caps the wait between two attempts to connect to neo4j
+	connectMaxBackoff = 10 * time.Second
+)
+
+// notConnected is the message of the requests rejected until neo4j is reachable
+const notConnected = "not connected to neo4j yet"
+
+// connection tells if the connectivity to neo4j was verified. A nil connection is always connected.
+type connection struct {
+	connected atomic.Bool
+}
+
+// connectInBackground verifies the connectivity to neo4j in the background, so that the server listens (reporting it
+// is not ready) meanwhile. The server exits if neo4j is still unreachable once timeout is reached.
+func connectInBackground(verify func() error, timeout time.Duration) *connection {
+	c := &connection{}
+	go func() {
+		if err := waitForNeo4j(verify, timeout, connectInitialBackoff, connectMaxBackoff); err != nil {
+			logrus.WithField("err", err).Fatal("neo4j is unreachable")
+		}
+		c.connected.Store(true)
+	}()
+	return c
+}
+
+func (c *connection) isConnected() bool {
+	return c == nil || c.connected.Load()
+}
+
+// waitForNeo4j calls verify until it succeeds, waiting with an exponential backoff between the attempts, so that the
+// server can start before neo4j is up. It gives up once timeout is reached.
+func waitForNeo4j(verify func() error, timeout time.Duration, initialBackoff time.Duration, maxBackoff time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := verify()
+		if err == nil {
+			if attempt > 1 {
+				logrus.Infof("connected to neo4j after %d attempts", attempt)
+			}
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("cannot connect to neo4j after %d attempts in %v: %w", attempt, timeout, err)
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		logrus.WithField("err", err).Warnf("cannot connect to neo4j (attempt %d), retrying in %v", attempt, backoff)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nzin/lexneo4j/internal/audit"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForNeo4j(t *testing.T) {
+	t.Run("up after a few attempts", func(t *testing.T) {
+		attempts := 0
+		err := waitForNeo4j(func() error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("connection refused")
+			}
+			return nil
+		}, time.Second, time.Millisecond, 2*time.Millisecond)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("never up", func(t *testing.T) {
+		attempts := 0
+		start := time.Now()
+		err := waitForNeo4j(func() error {
+			attempts++
+			return errors.New("connection refused")
+		}, 50*time.Millisecond, time.Millisecond, 10*time.Millisecond)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "connection refused")
+		assert.Less(t, time.Since(start), time.Second)
+		// 1ms, 2ms, 4ms, 8ms, then 10ms until the timeout
+		assert.GreaterOrEqual(t, attempts, 5)
+	})
+}
+
+func TestConnectInBackground(t *testing.T) {
+	up := make(chan struct{})
+	verified := make(chan struct{})
+	verify := func() error {
+		select {
+		case <-up:
+			close(verified)
+			return nil
+		default:
+			return errors.New("connection refused")
+		}
+	}
+
+	probe := func() (string, string, error) { return "Neo4j/4.4.5", "neo4j", nil }
+	var buf auditBuffer
+	c := &crud{
+		audit:      audit.NewLogger(&buf, nil),
+		connection: connectInBackground(verify, time.Minute),
+		inflight:   newInflight(),
+		readiness:  newReadiness(probe, time.Second, time.Minute),
+	}
+
+	// the server answers, but is not ready until neo4j is reachable
+	unavailable, isUnavailable := c.GetReady(health.GetReadyParams{}).(*health.GetReadyServiceUnavailable)
+	assert.True(t, isUnavailable)
+	assert.Equal(t, notConnected, unavailable.Payload.Message)
+
+	responder := c.DoCypher(app.DoCypherParams{
+		HTTPRequest: httptest.NewRequest(http.MethodPost, "/api/v1/cypher", nil),
+		Body:        app.DoCypherBody{Cmd: "MATCH (m:Movie) RETURN m.title"},
+	})
+	def, isDefault := responder.(*app.DoCypherDefault)
+	assert.True(t, isDefault)
+	assert.Equal(t, notConnected, *def.Payload.Message)
+	assert.Contains(t, buf.String(), `"status":503,"error":"`+notConnected+`"`)
+
+	close(up)
+	<-verified
+	assert.Eventually(t, c.connection.isConnected, time.Second, time.Millisecond)
+	_, isOK := c.GetReady(health.GetReadyParams{}).(*health.GetReadyOK)
+	assert.True(t, isOK)
+}
@@ -1,15 +1,32 @@
 package handler
 
 import (
+	"context"
+	"crypto/x509"
 	"fmt"
+	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/nzin/lexneo4j/internal/audit"
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/nzin/lexneo4j/internal/breaker"
+	"github.com/nzin/lexneo4j/internal/complexity"
 	"github.com/nzin/lexneo4j/internal/config"
+	"github.com/nzin/lexneo4j/internal/metrics"
 	"github.com/nzin/lexneo4j/internal/parser"
+	"github.com/nzin/lexneo4j/internal/policy"
+	"github.com/nzin/lexneo4j/internal/redact"
+	"github.com/nzin/lexneo4j/internal/slowlog"
+	"github.com/nzin/lexneo4j/internal/stats"
+	"github.com/nzin/lexneo4j/internal/tlsconfig"
+	"github.com/nzin/lexneo4j/internal/tracing"
+	"github.com/nzin/lexneo4j/internal/util"
 	"github.com/nzin/lexneo4j/swagger_gen/models"
 	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
 	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/health"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/go-openapi/runtime/middleware"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
@@ -19,43 +36,147 @@ import (
 type CRUD interface {
 	// healthcheck
 	GetHealthcheck(health.GetHealthParams) middleware.Responder
+	GetLive(health.GetLiveParams) middleware.Responder
+	GetReady(health.GetReadyParams) middleware.Responder
 	ListMovies(app.ListMoviesParams) middleware.Responder
 	DoCypher(app.DoCypherParams) middleware.Responder
+	ExplainCypher(app.ExplainCypherParams) middleware.Responder
+	ValidateCypher(app.ValidateCypherParams) middleware.Responder
+	GetQueryStats(app.GetQueryStatsParams) middleware.Responder
+	GetConfig(app.GetConfigParams) middleware.Responder
+
+	// configuration
+	ReloadPolicy() error
+
+	// shutdown
+	Drain()
+	Shutdown(ctx context.Context) error
 }
 
+// maxConnectionPoolSize is the maximum number of connections to neo4j
+const maxConnectionPoolSize = 10
+
 // NewCRUD creates a new CRUD instance
 func NewCRUD() CRUD {
-	neo4jdriver, err := neo4j.NewDriver(config.Config.Neo4jURL, neo4j.BasicAuth(config.Config.Neo4jUsername, config.Config.Neo4jPassword, ""), func(config *neo4j.Config) {
-		config.MaxConnectionLifetime = 1 * time.Minute
-		config.MaxConnectionPoolSize = 10
-		config.ConnectionAcquisitionTimeout = 5 * time.Second
-		config.SocketKeepalive = true
+	// the certificate authorities trusted to verify neo4j, the system ones if nil
+	var rootCAs *x509.CertPool
+	if config.Config.Neo4jCAFile != "" {
+		var err error
+		rootCAs, err = tlsconfig.LoadCertPool(config.Config.Neo4jCAFile)
+		if err != nil {
+			logrus.WithField("err", err).Fatalf("invalid neo4j CA bundle: %s", config.Config.Neo4jCAFile)
+		}
+	}
+
+	neo4jdriver, err := neo4j.NewDriver(config.Config.Neo4jURL, neo4j.BasicAuth(config.Config.Neo4jUsername, config.Config.Neo4jPassword, ""), func(driverConfig *neo4j.Config) {
+		driverConfig.MaxConnectionLifetime = 1 * time.Minute
+		driverConfig.MaxConnectionPoolSize = maxConnectionPoolSize
+		driverConfig.ConnectionAcquisitionTimeout = config.Config.Neo4jAcquisitionTimeout
+		driverConfig.MaxTransactionRetryTime = config.Config.Neo4jMaxRetryTime
+		driverConfig.SocketKeepalive = true
+		driverConfig.RootCAs = rootCAs
 	})
 	if err != nil {
-		panic(err)
+		logrus.WithField("err", err).Fatalf("cannot create the neo4j driver for %s", config.Config.Neo4jURL)
+	}
+	metrics.SetPoolSize(maxConnectionPoolSize)
+
+	accessPolicy, err := loadPolicy()
+	if err != nil {
+		logrus.WithField("err", err).Fatalf("failed to load the policy file: %s", config.Config.PolicyFile)
+	}
+
+	redactor, err := redact.NewRedactor(config.Config.RedactProperties, config.Config.RedactMode)
+	if err != nil {
+		logrus.WithField("err", err).Fatalf("invalid redaction configuration")
 	}
 
-	return &crud{
+	c := &crud{
 		neo4jdriver: neo4jdriver,
+		connection:  connectInBackground(neo4jdriver.VerifyConnectivity, config.Config.Neo4jConnectTimeout),
+		redactor:    redactor,
+		complexity: &complexity.Thresholds{
+			MaxScore:             config.Config.ComplexityMaxScore,
+			MaxPatterns:          config.Config.ComplexityMaxPatterns,
+			MaxUnlabeledNodes:    config.Config.ComplexityMaxUnlabeledNodes,
+			MaxUnboundedHops:     config.Config.ComplexityMaxUnboundedHops,
+			MaxCartesianProducts: config.Config.ComplexityMaxCartesianProducts,
+		},
+		audit: audit.NewFileLogger(audit.FileConfig{
+			Path:       config.Config.AuditFile,
+			MaxSizeMB:  config.Config.AuditMaxSizeMB,
+			MaxBackups: config.Config.AuditMaxBackups,
+			MaxAgeDays: config.Config.AuditMaxAgeDays,
+			Compress:   config.Config.AuditCompress,
+		}, config.Config.AuditRedactParameters),
+		stats:    stats.NewTable(config.Config.StatsMaxFingerprints),
+		slowlog:  slowlog.NewLogger(config.Config.SlowQueryThreshold, config.Config.SlowQueryFile),
+		inflight: newInflight(),
+		breaker:  newBreaker(),
 	}
+	c.policy.Store(accessPolicy)
+	c.readiness = newReadiness(c.probe, config.Config.HealthTimeout, config.Config.HealthCacheTTL)
+	return c
 }
 
 type crud struct {
 	neo4jdriver neo4j.Driver
+	// connection is verified in the background, the queries being rejected until it is
+	connection *connection
+	// policy is replaced when the policy file is reloaded
+	policy     atomic.Pointer[policy.Policy]
+	redactor   *redact.Redactor
+	complexity *complexity.Thresholds
+	audit      *audit.Logger
+	stats      *stats.Table
+	slowlog    *slowlog.Logger
+	readiness  *readiness
+	inflight   *inflight
+	// breaker fails the queries fast while neo4j is failing
+	breaker *breaker.Breaker
 }
 
 func (c *crud) GetHealthcheck(params health.GetHealthParams) middleware.Responder {
 	return health.NewGetHealthOK().WithPayload(&models.Health{Status: "OK"})
 }
 
-func (c *crud) ListMovies(app.ListMoviesParams) middleware.Responder {
-	session := c.neo4jdriver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+func (c *crud) ListMovies(params app.ListMoviesParams) middleware.Responder {
+	cypher, values := "MATCH (m:Movie) RETURN m.title,m.released", map[string]interface{}(nil)
+	if tenant := callerTenant(params.HTTPRequest); tenant != "" {
+		cypher, values = "MATCH (m:Movie {tenant: $tenant}) RETURN m.title,m.released", map[string]interface{}{"tenant": tenant}
+	}
+	entry := auditEntry(params.HTTPRequest, cypher)
+	entry.RenderedQuery, entry.Parameters = cypher, values
+
+	// the request leaves last, once its query is audited
+	if !c.inflight.enter() {
+		defer c.audit.Log(entry)
+		entry.Fail(503, shuttingDown)
+		return app.NewListMoviesDefault(503).WithPayload(ErrorMessage(shuttingDown))
+	}
+	defer c.inflight.leave()
+	defer c.audit.Log(entry)
+	if !c.connection.isConnected() {
+		entry.Fail(503, notConnected)
+		return app.NewListMoviesDefault(503).WithPayload(ErrorMessage(notConnected))
+	}
+
+	if err := c.breaker.Allow(); err != nil {
+		entry.Fail(503, neo4jUnavailable)
+		return app.NewListMoviesDefault(503).WithPayload(ErrorMessage(neo4jUnavailable))
+	}
+	// recorded even on a panic, not to leave the trial call of a half-open breaker in flight forever
+	var err error
+	defer func() { c.breaker.Done(err) }()
+	session := c.newSession()
 	defer session.Close()
 
+	_, span := startTransactionSpan(params.HTTPRequest.Context(), "neo4j.ReadTransaction", cypher)
+	start := time.Now()
 	movies, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
 		moviesList := make(map[string]int64)
 
-		result, err := tx.Run("MATCH (m:Movie) RETURN m.title,m.released", nil)
+		result, err := tx.Run(cypher, values)
 		if err != nil {
 			return nil, err
 		}
@@ -76,10 +197,15 @@ func (c *crud) ListMovies(app.ListMoviesParams) middleware.Responder {
 
 		return moviesList, nil
 	})
+	metrics.ObserveTransaction("listMovies", time.Since(start))
+	tracing.End(span, err)
 	if err != nil {
+		entry.Fail(500, fmt.Sprintf("cannot list movies: %v", err))
 		return app.NewListMoviesDefault(500).WithPayload(
 			ErrorMessage("cannot list movies: %v", err))
 	}
+	entry.Succeed(len(movies.(map[string]int64)))
+
 	listMovies := []*models.Movie{}
 	for title, released := range movies.(map[string]int64) {
 		listMovies = append(listMovies, &models.Movie{
@@ -95,37 +221,207 @@ func (c *crud) ListMovies(app.ListMoviesParams) middleware.Responder {
 	)
 }
 
-func (c *crud) DoCypher(params app.DoCypherParams) middleware.Responder {
-	parser := parser.NewParser(params.Body.Cmd)
+// prepareQuery parses and sanitizes a cypher command, before explaining or running it.
+// The returned error is either a *policy.Denial or an *Error.
+func (c *crud) prepareQuery(r *http.Request, cmd string) (*parser.CypherQuery, error) {
+	parser := parser.NewParser(cmd)
+	_, span := tracing.Start(r.Context(), "Parser.Parse")
 	query, err := parser.Parse()
+	tracing.End(span, err)
 	if err != nil {
-		return app.NewDoCypherDefault(500).WithPayload(
-			ErrorMessage("cannot parse query: %v", err))
+		metrics.ParseFailure(parseErrorKind(err))
+		return nil, NewError(500, "cannot parse query: %v", err)
 	}
 
 	if len(query.Return) == 0 {
-		return app.NewDoCypherDefault(500).WithPayload(
-			ErrorMessage("The query is missing a proper RETURN statement"))
+		metrics.ParseFailure("missing_return")
+		return nil, NewError(500, "The query is missing a proper RETURN statement")
+	}
+
+	if denial := c.policy.Load().Evaluate(query, callerRoles(r)); denial != nil {
+		metrics.PolicyDenial(denial.Kind)
+		return nil, denial
+	}
+
+	if !config.Config.CypherRegexEnabled && query.UsesRegex() {
+		return nil, NewError(403, "regular expressions (=~) are not allowed")
+	}
+
+	if err := c.complexity.Check(query); err != nil {
+		return nil, NewError(422, "%v", err)
+	}
+
+	if config.Config.CypherMaxRows > 0 {
+		query.CapRows(config.Config.CypherMaxRows)
+	}
+	return query, nil
+}
+
+// policyDenial returns the payload of a query denied by the access policy
+func policyDenial(denial *policy.Denial) *models.PolicyDenial {
+	return &models.PolicyDenial{
+		Message: util.StringPtr(denial.Error()),
+		Kind:    util.StringPtr(denial.Kind),
+		Element: denial.Element,
+	}
+}
+
+func (c *crud) ExplainCypher(params app.ExplainCypherParams) middleware.Responder {
+	entry := auditEntry(params.HTTPRequest, params.Body.Cmd)
+	fail := func(e *Error) middleware.Responder {
+		entry.Fail(e.StatusCode, fmt.Sprintf(e.Message, e.Values...))
+		return app.NewExplainCypherDefault(e.StatusCode).WithPayload(
+			ErrorMessage(e.Message, e.Values...))
+	}
+
+	// the request leaves last, once its query is audited
+	if !c.inflight.enter() {
+		defer c.audit.Log(entry)
+		return fail(NewError(503, shuttingDown))
+	}
+	defer c.inflight.leave()
+	defer c.audit.Log(entry)
+	if !c.connection.isConnected() {
+		return fail(NewError(503, notConnected))
+	}
+
+	query, err := c.prepareQuery(params.HTTPRequest, params.Body.Cmd)
+	if denial, ok := err.(*policy.Denial); ok {
+		entry.Fail(403, denial.Error())
+		return app.NewExplainCypherForbidden().WithPayload(policyDenial(denial))
+	}
+	if e, ok := err.(*Error); ok {
+		return fail(e)
+	}
+
+	cypher := callerCypher(params.HTTPRequest, query)
+	entry.RenderedQuery = cypher
+	timeout := queryTimeout(params.Body.TimeoutMs)
+	plan, err := c.explain(params.HTTPRequest.Context(), cypher, timeout)
+	if err != nil {
+		return fail(neo4jError(err, "explain", timeout))
+	}
+	// nothing is returned but the plan
+	entry.Succeed(0)
+
+	return app.NewExplainCypherOK().WithPayload(&models.CypherPlan{
+		Query: util.StringPtr(cypher),
+		Plan:  planNode(plan),
+	})
+}
+
+// explain returns the execution plan of the cypher command, as estimated by neo4j without running it
+func (c *crud) explain(ctx context.Context, cypher string, timeout time.Duration) (plan neo4j.Plan, err error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	session := c.newSession()
+	defer session.Close()
+
+	_, span := startTransactionSpan(ctx, "neo4j.Explain", cypher)
+	start := time.Now()
+	defer func() {
+		c.breaker.Done(err)
+		metrics.ObserveTransaction("explain", time.Since(start))
+		tracing.End(span, err)
+	}()
+	result, err := session.Run("EXPLAIN "+cypher, nil, neo4j.WithTxTimeout(timeout))
+	if err != nil {
+		return nil, err
+	}
+	summary, err := result.Consume()
+	if err != nil {
+		return nil, err
+	}
+	if summary.Plan() == nil {
+		return nil, fmt.Errorf("no plan returned")
+	}
+	return summary.Plan(), nil
+}
+
+func (c *crud) DoCypher(params app.DoCypherParams) middleware.Responder {
+	entry := auditEntry(params.HTTPRequest, params.Body.Cmd)
+	fail := func(e *Error) middleware.Responder {
+		entry.Fail(e.StatusCode, fmt.Sprintf(e.Message, e.Values...))
+		return app.NewDoCypherDefault(e.StatusCode).WithPayload(
+			ErrorMessage(e.Message, e.Values...))
+	}
+
+	// the request leaves last, once its query is audited
+	if !c.inflight.enter() {
+		defer c.audit.Log(entry)
+		return fail(NewError(503, shuttingDown))
+	}
+	defer c.inflight.leave()
+	defer c.audit.Log(entry)
+	if !c.connection.isConnected() {
+		return fail(NewError(503, notConnected))
+	}
+
+	query, err := c.prepareQuery(params.HTTPRequest, params.Body.Cmd)
+	if denial, ok := err.(*policy.Denial); ok {
+		entry.Fail(403, denial.Error())
+		return app.NewDoCypherForbidden().WithPayload(policyDenial(denial))
+	}
+	if e, ok := err.(*Error); ok {
+		return fail(e)
 	}
 
-	logrus.Infof("query: %s", query.ToString())
+	cypher := callerCypher(params.HTTPRequest, query)
+	entry.RenderedQuery = cypher
+	fingerprint := query.Fingerprint()
+	defer func() {
+		c.stats.Record(fingerprint, time.Since(entry.Time), entry.Rows, entry.Outcome != audit.OutcomeSuccess)
+	}()
 
-	session := c.neo4jdriver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	// the request context is done if the client goes away, or once the timeout is reached
+	timeout := queryTimeout(params.Body.TimeoutMs)
+	ctx, cancel := context.WithTimeout(params.HTTPRequest.Context(), timeout)
+	defer cancel()
+
+	// the plan, if already explained, is attached to the slow query log
+	var plan neo4j.Plan
+	if config.Config.CypherMaxEstimatedRows > 0 {
+		plan, err = c.explain(ctx, cypher, timeout)
+		if err != nil {
+			return fail(neo4jError(err, "explain", timeout))
+		}
+		if rows := estimatedRows(plan); rows > float64(config.Config.CypherMaxEstimatedRows) {
+			return fail(NewError(422, "query is too expensive: %.0f estimated rows exceed %d", rows, config.Config.CypherMaxEstimatedRows))
+		}
+	}
+
+	logrus.Infof("query: %s", cypher)
+
+	if err := c.breaker.Allow(); err != nil {
+		return fail(neo4jError(err, "run", timeout))
+	}
+	// recorded even on a panic, not to leave the trial call of a half-open breaker in flight forever
+	var runErr error
+	defer func() { c.breaker.Done(runErr) }()
+	session := c.newSession()
 	defer session.Close()
+	_, span := startTransactionSpan(ctx, "neo4j.ReadTransaction", cypher)
+	start := time.Now()
 	res, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
 		resList := make([]map[string]interface{}, 0)
 
-		result, err := tx.Run(query.ToString(), nil)
+		result, err := tx.Run(cypher, nil)
 		if err != nil {
 			return nil, err
 		}
 
 		for result.Next() {
+			// stop reading (and rollback the transaction, aborting the query) if the client is gone
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			record := result.Record()
 			res := make(map[string]interface{})
 			for _, k := range record.Keys {
 				res[k], _ = record.Get(k)
 			}
+			c.redactor.Record(query, res)
 			resList = append(resList, res)
 		}
 
@@ -135,11 +431,26 @@ func (c *crud) DoCypher(params app.DoCypherParams) middleware.Responder {
 		}
 
 		return resList, nil
-	})
+	}, neo4j.WithTxTimeout(timeout))
+	runErr = err
+	duration := time.Since(start)
+	metrics.ObserveTransaction("doCypher", duration)
+	if c.slowlog.IsSlow(duration) {
+		rows := 0
+		if err == nil {
+			rows = len(res.([]map[string]interface{}))
+		}
+		c.logSlowQuery(params.HTTPRequest, cypher, fingerprint, duration, rows, plan)
+	}
 	if err != nil {
-		return app.NewListMoviesDefault(500).WithPayload(
-			ErrorMessage("cannot list movies: %v", err))
+		tracing.End(span, err)
+		return fail(neo4jError(err, "run", timeout))
 	}
+	rows := len(res.([]map[string]interface{}))
+	span.SetAttributes(attribute.Int("db.rows", rows))
+	tracing.End(span, nil)
+	entry.Succeed(rows)
+	metrics.ObserveRows(rows)
 
 	results := make([]*app.DoCypherOKBodyResultItems0, 0)
 
@@ -183,3 +494,80 @@ func (c *crud) DoCypher(params app.DoCypherParams) middleware.Responder {
 		},
 	)
 }
+
+// logSlowQuery logs a query slower than the threshold. Unless it was already explained, its plan is captured in the
+// background if configured, not to delay the response any further.
+func (c *crud) logSlowQuery(r *http.Request, cypher string, fingerprint string, duration time.Duration, rows int, plan neo4j.Plan) {
+	entry := slowlog.Entry{
+		Query:       cypher,
+		Fingerprint: fingerprint,
+		Tenant:      callerTenant(r),
+		Duration:    duration,
+		Rows:        rows,
+	}
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		entry.Caller = identity.Name
+	}
+
+	if plan != nil || !config.Config.SlowQueryExplain {
+		if plan != nil {
+			entry.Plan = planNode(plan)
+		}
+		c.slowlog.Log(entry)
+		return
+	}
+	// the explain is in flight too, not to run on a closed driver
+	if !c.inflight.enter() {
+		c.slowlog.Log(entry)
+		return
+	}
+	go func() {
+		defer c.inflight.leave()
+		plan, err := c.explain(context.Background(), cypher, config.Config.CypherTimeout)
+		if err != nil {
+			logrus.WithField("err", err).Warn("cannot explain the slow query")
+		} else {
+			entry.Plan = planNode(plan)
+		}
+		c.slowlog.Log(entry)
+	}()
+}
+
+// callerRoles returns the roles of the caller, used to evaluate the access policy. The callers that are not
+// authenticated, or without any role, have the anonymous role.
+func callerRoles(r *http.Request) []string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil && len(identity.Roles) > 0 {
+		return identity.Roles
+	}
+	return []string{config.Config.PolicyAnonymousRole}
+}
+
+// callerTenant returns the tenant of the caller, or "" if the caller is not authenticated or has no tenant
+func callerTenant(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		return identity.Tenant
+	}
+	return ""
+}
+
+// callerCypher renders the query sent to neo4j, scoped to the tenant of the caller if it has one
+func callerCypher(r *http.Request, query *parser.CypherQuery) string {
+	_, span := tracing.Start(r.Context(), "CypherQuery.ToString")
+	defer span.End()
+
+	if tenant := callerTenant(r); tenant != "" {
+		span.SetAttributes(attribute.Bool("lexneo4j.tenant_scoped", true))
+		return query.ToStringWithTenant(tenant)
+	}
+	return query.ToString()
+}
+
+// auditEntry starts the audit record of a query run for the caller of the request
+func auditEntry(r *http.Request, query string) *audit.Entry {
+	entry := audit.NewEntry(r.URL.Path, query)
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		entry.Caller = identity.Name
+		entry.Tenant = identity.Tenant
+	}
+	return entry
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"github.com/nzin/lexneo4j/internal/config"
 	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations"
 	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
 	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/health"
@@ -11,9 +12,21 @@ func Setup(api *operations.Lexneo4jAPI) {
 	c := NewCRUD()
 
 	// healthcheck
-	api.HealthGetHealthHandler = health.GetHealthHandlerFunc(c.GetHealthcheck)
+	api.HealthGetHealthHandler = health.GetHealthHandlerFunc(instrument("health", c.GetHealthcheck))
+	api.HealthGetLiveHandler = health.GetLiveHandlerFunc(instrument("live", c.GetLive))
+	api.HealthGetReadyHandler = health.GetReadyHandlerFunc(instrument("ready", c.GetReady))
 
 	// neo4j functions
-	api.AppListMoviesHandler = app.ListMoviesHandlerFunc(c.ListMovies)
-	api.AppDoCypherHandler = app.DoCypherHandlerFunc(c.DoCypher)
+	api.AppListMoviesHandler = app.ListMoviesHandlerFunc(instrument("listMovies", c.ListMovies))
+	api.AppDoCypherHandler = app.DoCypherHandlerFunc(instrument("doCypher", c.DoCypher))
+	api.AppExplainCypherHandler = app.ExplainCypherHandlerFunc(instrument("explainCypher", c.ExplainCypher))
+	api.AppValidateCypherHandler = app.ValidateCypherHandlerFunc(instrument("validateCypher", c.ValidateCypher))
+	api.AppGetQueryStatsHandler = app.GetQueryStatsHandlerFunc(instrument("getQueryStats", c.GetQueryStats))
+	api.AppGetConfigHandler = app.GetConfigHandlerFunc(instrument("getConfig", c.GetConfig))
+
+	// the access policy is hot reloaded with the configuration
+	config.OnReload("access policy", c.ReloadPolicy)
+
+	// the queries in flight are drained before closing the neo4j driver
+	config.OnShutdown("neo4j", c)
 }
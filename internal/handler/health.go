@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/nzin/lexneo4j/swagger_gen/models"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/health"
+	"github.com/sirupsen/logrus"
+)
+
+// readiness checks if neo4j is reachable, caching the result not to probe neo4j on every request
+type readiness struct {
+	// probe connects to neo4j, returning its version and the name of its database
+	probe   func() (version string, database string, err error)
+	timeout time.Duration
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	status    *models.Health
+	ready     bool
+}
+
+func newReadiness(probe func() (string, string, error), timeout time.Duration, ttl time.Duration) *readiness {
+	return &readiness{probe: probe, timeout: timeout, ttl: ttl}
+}
+
+// check returns the status of neo4j, probing it if the cached status expired. The concurrent checks wait for the
+// same probe.
+func (r *readiness) check() (*models.Health, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status != nil && time.Since(r.checkedAt) < r.ttl {
+		return r.status, r.ready
+	}
+
+	type result struct {
+		version  string
+		database string
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		version, database, err := r.probe()
+		done <- result{version, database, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(r.timeout):
+		res.err = fmt.Errorf("no answer from neo4j after %v", r.timeout)
+	}
+
+	if res.err != nil {
+		logrus.WithField("err", res.err).Warn("neo4j is unreachable")
+		r.status, r.ready = &models.Health{Status: "UNAVAILABLE", Message: res.err.Error()}, false
+	} else {
+		r.status, r.ready = &models.Health{Status: "OK", Version: res.version, Database: res.database}, true
+	}
+	r.checkedAt = time.Now()
+	return r.status, r.ready
+}
+
+// probe verifies the connectivity to neo4j, and runs a trivial query to get the server version and database name
+func (c *crud) probe() (string, string, error) {
+	if err := c.neo4jdriver.VerifyConnectivity(); err != nil {
+		return "", "", err
+	}
+
+	session := c.newSession()
+	defer session.Close()
+	result, err := session.Run("RETURN 1", nil)
+	if err != nil {
+		return "", "", err
+	}
+	summary, err := result.Consume()
+	if err != nil {
+		return "", "", err
+	}
+	database := ""
+	if summary.Database() != nil {
+		database = summary.Database().Name()
+	}
+	return summary.Server().Agent(), database, nil
+}
+
+func (c *crud) GetLive(params health.GetLiveParams) middleware.Responder {
+	return health.NewGetLiveOK().WithPayload(&models.Health{Status: "OK"})
+}
+
+func (c *crud) GetReady(params health.GetReadyParams) middleware.Responder {
+	// not to receive new requests while draining
+	if c.inflight.isDraining() {
+		return health.NewGetReadyServiceUnavailable().WithPayload(&models.Health{Status: "UNAVAILABLE", Message: shuttingDown})
+	}
+	if !c.connection.isConnected() {
+		return health.NewGetReadyServiceUnavailable().WithPayload(&models.Health{Status: "UNAVAILABLE", Message: notConnected})
+	}
+	status, ready := c.readiness.check()
+	// the cached status is shared by the concurrent checks
+	withBreaker := *status
+	withBreaker.Breaker = string(c.breaker.State())
+	status = &withBreaker
+	if !ready {
+		return health.NewGetReadyServiceUnavailable().WithPayload(status)
+	}
+	return health.NewGetReadyOK().WithPayload(status)
+}
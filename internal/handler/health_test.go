@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness(t *testing.T) {
+	var probes int32
+	var probeErr error
+	probe := func() (string, string, error) {
+		atomic.AddInt32(&probes, 1)
+		if probeErr != nil {
+			return "", "", probeErr
+		}
+		return "Neo4j/4.4.5", "neo4j", nil
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		c := &crud{inflight: newInflight(), readiness: newReadiness(probe, time.Second, time.Minute)}
+		ok, isOK := c.GetReady(health.GetReadyParams{}).(*health.GetReadyOK)
+		assert.True(t, isOK)
+		assert.Equal(t, "OK", ok.Payload.Status)
+		assert.Equal(t, "Neo4j/4.4.5", ok.Payload.Version)
+		assert.Equal(t, "neo4j", ok.Payload.Database)
+
+		// the result is cached
+		c.GetReady(health.GetReadyParams{})
+		assert.Equal(t, int32(1), atomic.LoadInt32(&probes))
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		probeErr = errors.New("connection refused")
+		defer func() { probeErr = nil }()
+
+		c := &crud{inflight: newInflight(), readiness: newReadiness(probe, time.Second, 0)}
+		unavailable, isUnavailable := c.GetReady(health.GetReadyParams{}).(*health.GetReadyServiceUnavailable)
+		assert.True(t, isUnavailable)
+		assert.Equal(t, "UNAVAILABLE", unavailable.Payload.Status)
+		assert.Equal(t, "connection refused", unavailable.Payload.Message)
+
+		// without cache, neo4j is probed again
+		probeErr = nil
+		_, isOK := c.GetReady(health.GetReadyParams{}).(*health.GetReadyOK)
+		assert.True(t, isOK)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		slow := func() (string, string, error) {
+			<-release
+			return "Neo4j/4.4.5", "neo4j", nil
+		}
+
+		c := &crud{inflight: newInflight(), readiness: newReadiness(slow, 10*time.Millisecond, time.Minute)}
+		unavailable, isUnavailable := c.GetReady(health.GetReadyParams{}).(*health.GetReadyServiceUnavailable)
+		assert.True(t, isUnavailable)
+		assert.Equal(t, "no answer from neo4j after 10ms", unavailable.Payload.Message)
+	})
+
+	t.Run("live", func(t *testing.T) {
+		c := &crud{}
+		_, isOK := c.GetLive(health.GetLiveParams{}).(*health.GetLiveOK)
+		assert.True(t, isOK)
+	})
+}
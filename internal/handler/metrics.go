@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/metrics"
+	"github.com/nzin/lexneo4j/internal/parser"
+)
+
+// instrument records the requests of an operation, with their status code and duration (including the writing of
+// the response)
+func instrument[P any](operation string, handle func(P) middleware.Responder) func(P) middleware.Responder {
+	return func(params P) middleware.Responder {
+		start := time.Now()
+		responder := handle(params)
+		return middleware.ResponderFunc(func(rw http.ResponseWriter, producer runtime.Producer) {
+			recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+			responder.WriteResponse(recorder, producer)
+			metrics.ObserveRequest(operation, recorder.status, time.Since(start))
+		})
+	}
+}
+
+// statusRecorder records the status code written by a responder
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// parseErrorKind returns the kind of a parsing error: a syntax error, or a semantic one (i.e. an undefined variable)
+func parseErrorKind(err error) string {
+	if parseErr, ok := err.(*parser.ParseError); ok && !parseErr.Semantic {
+		return "syntax"
+	}
+	return "semantic"
+}
+
+// newSession opens a read session, counted in the sessions in use until it is closed
+func (c *crud) newSession() neo4j.Session {
+	metrics.SessionOpened()
+	return &trackedSession{Session: c.neo4jdriver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})}
+}
+
+type trackedSession struct {
+	neo4j.Session
+	once sync.Once
+}
+
+func (s *trackedSession) Close() error {
+	s.once.Do(metrics.SessionClosed)
+	return s.Session.Close()
+}
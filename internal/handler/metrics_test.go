@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-openapi/runtime"
+	"github.com/nzin/lexneo4j/internal/metrics"
+	"github.com/nzin/lexneo4j/internal/parser"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	c := &crud{}
+	validate := instrument("validateCypher", c.ValidateCypher)
+	responder := validate(app.ValidateCypherParams{
+		HTTPRequest: httptest.NewRequest(http.MethodPost, "/api/v1/cypher/validate", nil),
+		Body:        app.ValidateCypherBody{Cmd: "MATCH (m:Movie) RETURN m.title"},
+	})
+	rw := httptest.NewRecorder()
+	responder.WriteResponse(rw, runtime.JSONProducer())
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"valid":true`)
+
+	metricsRW := httptest.NewRecorder()
+	metrics.NewMiddleware("/metrics").ServeHTTP(metricsRW, httptest.NewRequest(http.MethodGet, "/metrics", nil), nil)
+	assert.Contains(t, metricsRW.Body.String(), `lexneo4j_requests_total{code="200",operation="validateCypher"} 1`)
+}
+
+func TestParseErrorKind(t *testing.T) {
+	_, err := parser.NewParser("MATCH (m:Movie RETURN m").Parse()
+	assert.Equal(t, "syntax", parseErrorKind(err))
+	_, err = parser.NewParser("MATCH (m:Movie) RETURN n").Parse()
+	assert.Equal(t, "semantic", parseErrorKind(err))
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/util"
+	"github.com/nzin/lexneo4j/swagger_gen/models"
+)
+
+// planNode converts an execution plan returned by neo4j
+func planNode(plan neo4j.Plan) *models.CypherPlanNode {
+	node := &models.CypherPlanNode{
+		Operator:      util.StringPtr(plan.Operator()),
+		EstimatedRows: estimatedRows(plan),
+		Identifiers:   plan.Identifiers(),
+		Arguments:     plan.Arguments(),
+		Children:      []*models.CypherPlanNode{},
+	}
+	for _, child := range plan.Children() {
+		node.Children = append(node.Children, planNode(child))
+	}
+	return node
+}
+
+// estimatedRows returns the number of rows estimated by the planner for a plan operator (0 if unknown).
+// The estimation of the root operator is the one of the whole query.
+func estimatedRows(plan neo4j.Plan) float64 {
+	switch rows := plan.Arguments()["EstimatedRows"].(type) {
+	case float64:
+		return rows
+	case int64:
+		return float64(rows)
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/audit"
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/nzin/lexneo4j/internal/breaker"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPlan struct {
+	operator    string
+	arguments   map[string]interface{}
+	identifiers []string
+	children    []neo4j.Plan
+}
+
+func (p *testPlan) Operator() string                  { return p.operator }
+func (p *testPlan) Arguments() map[string]interface{} { return p.arguments }
+func (p *testPlan) Identifiers() []string             { return p.identifiers }
+func (p *testPlan) Children() []neo4j.Plan            { return p.children }
+
+func TestPlanNode(t *testing.T) {
+	plan := &testPlan{
+		operator:    "ProduceResults@neo4j",
+		arguments:   map[string]interface{}{"EstimatedRows": 38.0, "planner": "COST"},
+		identifiers: []string{"m"},
+		children: []neo4j.Plan{
+			&testPlan{
+				operator:    "NodeByLabelScan@neo4j",
+				arguments:   map[string]interface{}{"EstimatedRows": int64(38)},
+				identifiers: []string{"m"},
+			},
+		},
+	}
+
+	node := planNode(plan)
+	assert.Equal(t, "ProduceResults@neo4j", *node.Operator)
+	assert.Equal(t, 38.0, node.EstimatedRows)
+	assert.Equal(t, []string{"m"}, node.Identifiers)
+	assert.Len(t, node.Children, 1)
+	assert.Equal(t, "NodeByLabelScan@neo4j", *node.Children[0].Operator)
+	assert.Equal(t, 38.0, node.Children[0].EstimatedRows)
+	assert.Empty(t, node.Children[0].Children)
+
+	assert.Equal(t, 0.0, estimatedRows(&testPlan{arguments: map[string]interface{}{}}))
+}
+
+type auditBuffer struct {
+	bytes.Buffer
+}
+
+func (b *auditBuffer) Close() error {
+	return nil
+}
+
+func TestExplainCypherAudit(t *testing.T) {
+	var buf auditBuffer
+	c := &crud{
+		inflight: newInflight(),
+		audit:    audit.NewLogger(&buf, nil),
+		breaker:  breaker.New(1, time.Minute, isNeo4jFailure),
+	}
+	explain := func(cmd string) {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/cypher/explain", nil)
+		r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{Name: "alice", Tenant: "acme"}))
+		c.ExplainCypher(app.ExplainCypherParams{HTTPRequest: r, Body: app.ExplainCypherBody{Cmd: cmd}})
+	}
+
+	explain("MATCH (m:Movie")
+	c.breaker.Allow()
+	c.breaker.Done(&neo4j.ConnectivityError{})
+	explain("MATCH (m:Movie) RETURN m.title")
+
+	entries := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		entry := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	assert.Len(t, entries, 2)
+
+	assert.Equal(t, "/api/v1/cypher/explain", entries[0]["endpoint"])
+	assert.Equal(t, "alice", entries[0]["caller"])
+	assert.Equal(t, "acme", entries[0]["tenant"])
+	assert.Equal(t, "MATCH (m:Movie", entries[0]["query"])
+	assert.Equal(t, "error", entries[0]["outcome"])
+	assert.Equal(t, float64(500), entries[0]["status"])
+
+	assert.Contains(t, entries[1]["renderedQuery"], "MATCH (m:Movie{tenant:'acme'}) RETURN m.title")
+	assert.Equal(t, "error", entries[1]["outcome"])
+	assert.Equal(t, float64(503), entries[1]["status"])
+	assert.Equal(t, neo4jUnavailable, entries[1]["error"])
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// shuttingDown is the message of the requests rejected while draining
+const shuttingDown = "the server is shutting down"
+
+// inflight counts the requests running neo4j transactions, so that the shutdown waits for them before closing the
+// driver
+type inflight struct {
+	mu       sync.Mutex
+	count    int
+	draining bool
+	// idle is closed once draining with no request in flight
+	idle chan struct{}
+}
+
+func newInflight() *inflight {
+	return &inflight{idle: make(chan struct{})}
+}
+
+// enter counts a request in flight. It returns false once draining, the request being rejected.
+func (f *inflight) enter() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.draining {
+		return false
+	}
+	f.count++
+	return true
+}
+
+// leave ends a request counted by enter
+func (f *inflight) leave() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count--
+	if f.draining && f.count == 0 {
+		close(f.idle)
+	}
+}
+
+// drain rejects the new requests
+func (f *inflight) drain() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.draining {
+		return
+	}
+	f.draining = true
+	if f.count == 0 {
+		close(f.idle)
+	}
+}
+
+func (f *inflight) isDraining() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.draining
+}
+
+// wait drains the requests, waiting for the ones in flight until ctx is done. It returns the number of requests
+// still in flight.
+func (f *inflight) wait(ctx context.Context) int {
+	f.drain()
+	select {
+	case <-f.idle:
+	case <-ctx.Done():
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.count
+}
+
+// Drain rejects the new queries with a 503, and reports neo4j as not ready
+func (c *crud) Drain() {
+	c.inflight.drain()
+}
+
+// Shutdown waits for the queries in flight until ctx is done, then closes the neo4j driver, the audit log and the
+// slow query log
+func (c *crud) Shutdown(ctx context.Context) error {
+	logrus.Info("waiting for the neo4j transactions in flight")
+	if remaining := c.inflight.wait(ctx); remaining > 0 {
+		logrus.Warnf("%d neo4j transactions still in flight after the shutdown timeout, closing the driver anyway", remaining)
+	} else {
+		logrus.Info("neo4j transactions drained")
+	}
+
+	if err := c.audit.Close(); err != nil {
+		logrus.WithField("err", err).Error("failed to close the audit log")
+	}
+	if err := c.slowlog.Close(); err != nil {
+		logrus.WithField("err", err).Error("failed to close the slow query log")
+	}
+	logrus.Info("closing the neo4j driver")
+	return c.neo4jdriver.Close()
+}
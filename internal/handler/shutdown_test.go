@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzin/lexneo4j/internal/audit"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInflight(t *testing.T) {
+	t.Run("drained", func(t *testing.T) {
+		f := newInflight()
+		assert.True(t, f.enter())
+		assert.True(t, f.enter())
+
+		done := make(chan int)
+		go func() { done <- f.wait(context.Background()) }()
+		assert.Eventually(t, f.isDraining, time.Second, time.Millisecond)
+		assert.False(t, f.enter())
+
+		f.leave()
+		select {
+		case <-done:
+			t.Fatal("a request is still in flight")
+		case <-time.After(20 * time.Millisecond):
+		}
+		f.leave()
+		assert.Equal(t, 0, <-done)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		f := newInflight()
+		assert.True(t, f.enter())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, 1, f.wait(ctx))
+
+		// the late request can still leave
+		f.leave()
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		f := newInflight()
+		f.drain()
+		f.drain()
+		assert.Equal(t, 0, f.wait(context.Background()))
+	})
+}
+
+func TestDrain(t *testing.T) {
+	var buf auditBuffer
+	c := &crud{inflight: newInflight(), audit: audit.NewLogger(&buf, nil)}
+	c.Drain()
+
+	responder := c.DoCypher(app.DoCypherParams{
+		HTTPRequest: httptest.NewRequest(http.MethodPost, "/api/v1/cypher", nil),
+		Body:        app.DoCypherBody{Cmd: "MATCH (m:Movie) RETURN m.title"},
+	})
+	def, ok := responder.(*app.DoCypherDefault)
+	assert.True(t, ok)
+	assert.Equal(t, shuttingDown, *def.Payload.Message)
+	c.ExplainCypher(app.ExplainCypherParams{
+		HTTPRequest: httptest.NewRequest(http.MethodPost, "/api/v1/cypher/explain", nil),
+		Body:        app.ExplainCypherBody{Cmd: "MATCH (m:Movie) RETURN m.title"},
+	})
+	c.ListMovies(app.ListMoviesParams{HTTPRequest: httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)})
+
+	// the rejected requests are audited too
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	for _, line := range lines {
+		assert.Contains(t, line, `"status":503,"error":"`+shuttingDown+`"`)
+	}
+
+	unavailable, ok := c.GetReady(health.GetReadyParams{}).(*health.GetReadyServiceUnavailable)
+	assert.True(t, ok)
+	assert.Equal(t, shuttingDown, unavailable.Payload.Message)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/nzin/lexneo4j/internal/util"
+	"github.com/nzin/lexneo4j/swagger_gen/models"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+)
+
+func (c *crud) GetQueryStats(params app.GetQueryStatsParams) middleware.Responder {
+	// the fingerprints are the queries of all the tenants
+	if !isAdmin(params.HTTPRequest) {
+		return app.NewGetQueryStatsForbidden().WithPayload(ErrorMessage("an admin role is required"))
+	}
+
+	queries := []*models.QueryStats{}
+	for _, s := range c.stats.Snapshot() {
+		count := s.Count
+		queries = append(queries, &models.QueryStats{
+			ID:          util.StringPtr(s.ID),
+			Fingerprint: util.StringPtr(s.Fingerprint),
+			Count:       &count,
+			Errors:      s.Errors,
+			ErrorRate:   s.ErrorRate,
+			P50Ms:       milliseconds(s.P50),
+			P95Ms:       milliseconds(s.P95),
+			TotalTimeMs: milliseconds(s.TotalTime),
+			Rows:        s.Rows,
+			MeanRows:    s.MeanRows,
+		})
+	}
+	return app.NewGetQueryStatsOK().WithPayload(&app.GetQueryStatsOKBody{Queries: queries})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
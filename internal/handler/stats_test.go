@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/nzin/lexneo4j/internal/config"
+	"github.com/nzin/lexneo4j/internal/stats"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetQueryStats(t *testing.T) {
+	saved := config.Config.AdminRoles
+	defer func() { config.Config.AdminRoles = saved }()
+	config.Config.AdminRoles = []string{"admin"}
+
+	params := func(roles ...string) app.GetQueryStatsParams {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/stats/queries", nil)
+		r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{Name: "alice", Roles: roles}))
+		return app.GetQueryStatsParams{HTTPRequest: r}
+	}
+
+	c := &crud{stats: stats.NewTable(10)}
+	c.stats.Record("MATCH (v1:Movie) RETURN v1.title", 1500*time.Microsecond, 38, false)
+	c.stats.Record("MATCH (v1:Movie) RETURN v1.title", 2500*time.Microsecond, 0, true)
+
+	_, forbidden := c.GetQueryStats(params("reader")).(*app.GetQueryStatsForbidden)
+	assert.True(t, forbidden)
+
+	responder := c.GetQueryStats(params("admin"))
+	ok, isOK := responder.(*app.GetQueryStatsOK)
+	assert.True(t, isOK)
+	assert.Len(t, ok.Payload.Queries, 1)
+
+	q := ok.Payload.Queries[0]
+	assert.Equal(t, "MATCH (v1:Movie) RETURN v1.title", *q.Fingerprint)
+	assert.Equal(t, int64(2), *q.Count)
+	assert.Equal(t, int64(1), q.Errors)
+	assert.Equal(t, 0.5, q.ErrorRate)
+	assert.Equal(t, 1.5, q.P50Ms)
+	assert.Equal(t, 4.0, q.TotalTimeMs)
+	assert.Equal(t, int64(38), q.Rows)
+
+	// without any query
+	c = &crud{stats: stats.NewTable(10)}
+	ok = c.GetQueryStats(params("admin")).(*app.GetQueryStatsOK)
+	assert.Empty(t, ok.Payload.Queries)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/breaker"
+	"github.com/nzin/lexneo4j/internal/config"
+)
+
+// queryTimeout returns the timeout of a query: the one asked by the client (in milliseconds) capped by the
+// configuration, or the default one if the client did not ask for any
+func queryTimeout(timeoutMs int64) time.Duration {
+	if timeoutMs <= 0 {
+		return config.Config.CypherTimeout
+	}
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if timeout > config.Config.CypherMaxTimeout {
+		return config.Config.CypherMaxTimeout
+	}
+	return timeout
+}
+
+// isTimeout returns true if the query was aborted by neo4j because of its transaction timeout, or while reading its
+// results because the request context timed out
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var neo4jErr *neo4j.Neo4jError
+	return errors.As(err, &neo4jErr) && strings.HasPrefix(neo4jErr.Code, "Neo.ClientError.Transaction.TransactionTimedOut")
+}
+
+// neo4jError converts an error returned while explaining or running a query
+func neo4jError(err error, action string, timeout time.Duration) *Error {
+	switch {
+	case errors.Is(err, breaker.ErrOpen):
+		return NewError(503, neo4jUnavailable)
+	case isTimeout(err):
+		return NewError(504, "query timed out after %v", timeout)
+	case errors.Is(err, context.Canceled):
+		// nobody is there to read it
+		return NewError(499, "query canceled by the client")
+	}
+	return NewError(500, "cannot %s query: %v", action, err)
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/breaker"
+	"github.com/nzin/lexneo4j/internal/config"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	config.Config.CypherTimeout = 30 * time.Second
+	config.Config.CypherMaxTimeout = 2 * time.Minute
+
+	assert.Equal(t, 30*time.Second, queryTimeout(0))
+	assert.Equal(t, 500*time.Millisecond, queryTimeout(500))
+	assert.Equal(t, 2*time.Minute, queryTimeout(int64(time.Hour/time.Millisecond)))
+}
+
+func TestNeo4jError(t *testing.T) {
+	timeout := 500 * time.Millisecond
+
+	e := neo4jError(&neo4j.Neo4jError{Code: "Neo.ClientError.Transaction.TransactionTimedOut", Msg: "timed out"}, "run", timeout)
+	assert.Equal(t, 504, e.StatusCode)
+	assert.Equal(t, "query timed out after 500ms", fmt.Sprintf(e.Message, e.Values...))
+
+	e = neo4jError(fmt.Errorf("reading: %w", context.DeadlineExceeded), "run", timeout)
+	assert.Equal(t, 504, e.StatusCode)
+
+	e = neo4jError(context.Canceled, "run", timeout)
+	assert.Equal(t, 499, e.StatusCode)
+
+	e = neo4jError(&neo4j.Neo4jError{Code: "Neo.ClientError.Statement.SyntaxError", Msg: "syntax"}, "explain", timeout)
+	assert.Equal(t, 500, e.StatusCode)
+
+	e = neo4jError(breaker.ErrOpen, "run", timeout)
+	assert.Equal(t, 503, e.StatusCode)
+}
+
+// rowsDriver opens sessions whose transactions return the given rows, calling onRecord once each row is read
+type rowsDriver struct {
+	neo4j.Driver
+	titles   []string
+	onRecord func()
+	records  int
+}
+
+func (d *rowsDriver) NewSession(neo4j.SessionConfig) neo4j.Session {
+	return &rowsSession{driver: d}
+}
+
+type rowsSession struct {
+	neo4j.Session
+	driver *rowsDriver
+}
+
+func (s *rowsSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(&rowsTransaction{driver: s.driver})
+}
+
+func (s *rowsSession) Close() error {
+	return nil
+}
+
+type rowsTransaction struct {
+	neo4j.Transaction
+	driver *rowsDriver
+}
+
+func (tx *rowsTransaction) Run(string, map[string]interface{}) (neo4j.Result, error) {
+	return &rowsResult{driver: tx.driver, next: -1}, nil
+}
+
+type rowsResult struct {
+	neo4j.Result
+	driver *rowsDriver
+	next   int
+}
+
+func (r *rowsResult) Next() bool {
+	r.next++
+	return r.next < len(r.driver.titles)
+}
+
+func (r *rowsResult) Record() *neo4j.Record {
+	r.driver.records++
+	r.driver.onRecord()
+	return &neo4j.Record{Keys: []string{"m.title"}, Values: []interface{}{r.driver.titles[r.next]}}
+}
+
+func (r *rowsResult) Err() error {
+	return nil
+}
+
+func TestDoCypherCanceled(t *testing.T) {
+	saved := config.Config.CypherTimeout
+	defer func() { config.Config.CypherTimeout = saved }()
+	config.Config.CypherTimeout = time.Minute
+
+	// the client goes away once the first row is read
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	driver := &rowsDriver{titles: []string{"The Matrix", "Top Gun", "Apollo 13"}, onRecord: cancel}
+	c := &crud{neo4jdriver: driver, inflight: newInflight()}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/cypher", nil).WithContext(ctx)
+	responder := c.DoCypher(app.DoCypherParams{
+		HTTPRequest: r,
+		Body:        app.DoCypherBody{Cmd: "MATCH (m:Movie) RETURN m.title"},
+	})
+
+	def, isDefault := responder.(*app.DoCypherDefault)
+	assert.True(t, isDefault)
+	assert.Equal(t, "query canceled by the client", *def.Payload.Message)
+	rec := httptest.NewRecorder()
+	def.WriteResponse(rec, runtime.JSONProducer())
+	assert.Equal(t, 499, rec.Code)
+	// the next rows are not read
+	assert.Equal(t, 1, driver.records)
+}
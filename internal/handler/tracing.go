@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/nzin/lexneo4j/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startTransactionSpan starts the span of a neo4j transaction running the cypher command
+func startTransactionSpan(ctx context.Context, name string, cypher string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, semconv.DBSystemNeo4j, semconv.DBQueryText(cypher))
+}
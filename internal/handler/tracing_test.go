@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/nzin/lexneo4j/internal/config"
+	"github.com/nzin/lexneo4j/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(tracing.NewProvider(tracing.Config{SampleRatio: 1}, sdktrace.WithSyncer(exporter)))
+
+	ctx, request := tracing.Start(context.Background(), "POST /api/v1/cypher")
+	ctx = auth.WithIdentity(ctx, &auth.Identity{Name: "alice", Tenant: "acme"})
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/cypher", nil).WithContext(ctx)
+
+	saved := config.Config.CypherMaxRows
+	defer func() { config.Config.CypherMaxRows = saved }()
+	config.Config.CypherMaxRows = 1000
+
+	c := &crud{}
+	query, err := c.prepareQuery(r, "MATCH (m:Movie) RETURN m.title")
+	assert.Nil(t, err)
+	assert.Equal(t, "MATCH (m:Movie{tenant:'acme'}) RETURN m.title LIMIT 1000", callerCypher(r, query))
+	_, err = c.prepareQuery(r, "MATCH (m:Movie RETURN m.title")
+	assert.NotNil(t, err)
+	request.End()
+
+	spans := exporter.GetSpans()
+	names := []string{}
+	for _, span := range spans {
+		names = append(names, span.Name)
+		if span.Name != "POST /api/v1/cypher" {
+			assert.Equal(t, request.SpanContext().SpanID(), span.Parent.SpanID(), span.Name)
+		}
+	}
+	assert.Equal(t, []string{"Parser.Parse", "CypherQuery.ToString", "Parser.Parse", "POST /api/v1/cypher"}, names)
+	assert.Equal(t, "Error", spans[2].Status.Code.String())
+}
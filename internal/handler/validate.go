@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/nzin/lexneo4j/internal/parser"
+	"github.com/nzin/lexneo4j/internal/util"
+	"github.com/nzin/lexneo4j/swagger_gen/models"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+)
+
+// ValidateCypher parses a cypher command and describes it, without opening a neo4j session
+func (c *crud) ValidateCypher(params app.ValidateCypherParams) middleware.Responder {
+	validation := &models.CypherValidation{
+		Valid:     util.BoolPtr(false),
+		Variables: []string{},
+		Columns:   []string{},
+		Errors:    []*models.CypherError{},
+	}
+
+	query, err := parser.NewParser(params.Body.Cmd).Parse()
+	if err != nil {
+		cypherError := &models.CypherError{Message: util.StringPtr(err.Error())}
+		if parseErr, ok := err.(*parser.ParseError); ok {
+			cypherError.Message = util.StringPtr(parseErr.Message)
+			cypherError.Position = int64(parseErr.Pos)
+			cypherError.Line = int64(parseErr.Line)
+			cypherError.Column = int64(parseErr.Column)
+		}
+		validation.Errors = append(validation.Errors, cypherError)
+		return app.NewValidateCypherOK().WithPayload(validation)
+	}
+
+	validation.Query = query.ToString()
+	// the callers with a tenant can only validate the queries scoped to it
+	tenant := params.Body.Tenant
+	if callerTenant := callerTenant(params.HTTPRequest); callerTenant != "" {
+		tenant = callerTenant
+	}
+	if tenant != "" {
+		validation.TenantQuery = query.ToStringWithTenant(tenant)
+	}
+	validation.Variables = query.Variables()
+	for _, ret := range query.Return {
+		validation.Columns = append(validation.Columns, ret.Name())
+	}
+
+	if len(query.Return) == 0 {
+		validation.Errors = append(validation.Errors, &models.CypherError{
+			Message: util.StringPtr("The query is missing a proper RETURN statement"),
+		})
+		return app.NewValidateCypherOK().WithPayload(validation)
+	}
+
+	validation.Valid = util.BoolPtr(true)
+	return app.NewValidateCypherOK().WithPayload(validation)
+}
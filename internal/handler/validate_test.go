@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/nzin/lexneo4j/swagger_gen/models"
+	"github.com/nzin/lexneo4j/swagger_gen/restapi/operations/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func validate(t *testing.T, cmd string, tenant string) *models.CypherValidation {
+	return validateAs(t, nil, cmd, tenant)
+}
+
+func validateAs(t *testing.T, identity *auth.Identity, cmd string, tenant string) *models.CypherValidation {
+	c := &crud{}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/cypher/validate", nil)
+	if identity != nil {
+		r = r.WithContext(auth.WithIdentity(r.Context(), identity))
+	}
+	responder := c.ValidateCypher(app.ValidateCypherParams{HTTPRequest: r, Body: app.ValidateCypherBody{Cmd: cmd, Tenant: tenant}})
+	ok, isOK := responder.(*app.ValidateCypherOK)
+	assert.True(t, isOK)
+	return ok.Payload
+}
+
+func TestValidateCypher(t *testing.T) {
+	t.Run("valid query", func(t *testing.T) {
+		validation := validate(t, "MATCH (a:Person)-[:ACTED_IN]->(m:Movie) WITH a, count(m) AS movies RETURN a.name, movies", "TENANT")
+		assert.True(t, *validation.Valid)
+		assert.Equal(t, "MATCH (a:Person)-[:ACTED_IN]->(m:Movie) WITH a,count(m) AS movies RETURN a.name,movies", validation.Query)
+		assert.Equal(t, "MATCH (a:Person{tenant:'TENANT'})-[:ACTED_IN{tenant:'TENANT'}]->(m:Movie{tenant:'TENANT'}) WITH a,count(m) AS movies RETURN a.name,movies", validation.TenantQuery)
+		assert.Equal(t, []string{"a", "m", "movies"}, validation.Variables)
+		assert.Equal(t, []string{"a.name", "movies"}, validation.Columns)
+		assert.Empty(t, validation.Errors)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		validation := validate(t, "MATCH (a:Person)\nRETURN a.name AS", "")
+		assert.False(t, *validation.Valid)
+		assert.Equal(t, "", validation.Query)
+		assert.Len(t, validation.Errors, 1)
+		assert.Equal(t, int64(2), validation.Errors[0].Line)
+		assert.Equal(t, int64(17), validation.Errors[0].Column)
+	})
+
+	t.Run("semantic errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			cmd     string
+			message string
+			line    int64
+			column  int64
+		}{
+			{"MATCH (a:Person)\nRETURN b", "variable 'b' is not defined", 2, 8},
+			{"MATCH (a:Person)\nWHERE c.born > 1970 RETURN a", "variable 'c' is not defined", 2, 7},
+			{"MATCH (a:Person)\nWHERE (a)-[:KNOWS]->(b) RETURN a", "variable 'b' is not defined (a pattern predicate cannot introduce new variables)", 2, 7},
+			{"MATCH (a:Person) RETURN a.name AS name\nUNION MATCH (m:Movie) RETURN m.title", "all queries of a UNION must return the same columns ('m.title' is not returned by the first query)", 2, 30},
+			{"MATCH (a:Person) RETURN a.name AS name\nUNION MATCH (m:Movie) RETURN m.title AS name, m.released", "all queries of a UNION must return the same columns ('m.released' is not returned by the first query)", 2, 47},
+			{"MATCH (a:Person) RETURN a.name AS name, a.born AS born\nUNION MATCH (m:Movie) RETURN m.title AS name", "all queries of a UNION must return the same columns", 2, 1},
+			{"MATCH (a:Person)\nRETURN a.name, a.name", "column 'a.name' is returned twice", 2, 16},
+		} {
+			validation := validate(t, tc.cmd, "")
+			assert.False(t, *validation.Valid, tc.cmd)
+			if assert.Len(t, validation.Errors, 1, tc.cmd) {
+				assert.Equal(t, tc.message, *validation.Errors[0].Message, tc.cmd)
+				assert.Equal(t, tc.line, validation.Errors[0].Line, tc.cmd)
+				assert.Equal(t, tc.column, validation.Errors[0].Column, tc.cmd)
+			}
+		}
+
+		// the position is counted in runes
+		validation := validate(t, "MATCH (a:Person) RETURN b", "")
+		assert.Equal(t, int64(24), validation.Errors[0].Position)
+	})
+
+	t.Run("missing RETURN", func(t *testing.T) {
+		validation := validate(t, "MATCH (a:Person)", "")
+		assert.False(t, *validation.Valid)
+		assert.Equal(t, "MATCH (a:Person)", validation.Query)
+		assert.Len(t, validation.Errors, 1)
+	})
+
+	t.Run("caller tenant", func(t *testing.T) {
+		identity := &auth.Identity{Name: "alice", Tenant: "ACME"}
+		validation := validateAs(t, identity, "MATCH (m:Movie) RETURN m.title", "OTHER")
+		assert.Equal(t, "MATCH (m:Movie{tenant:'ACME'}) RETURN m.title", validation.TenantQuery)
+
+		validation = validateAs(t, &auth.Identity{Name: "bob"}, "MATCH (m:Movie) RETURN m.title", "")
+		assert.Equal(t, "", validation.TenantQuery)
+	})
+}
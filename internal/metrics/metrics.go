@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lexneo4j"
+
+// Registry holds the metrics of the app, along with the go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Number of API requests, per operation and status code.",
+	}, []string{"operation", "code"})
+	requestDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the API requests, per operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	parseFailures = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cypher_parse_failures_total",
+		Help:      "Number of cypher commands failing to parse, per kind of error.",
+	}, []string{"kind"})
+	policyDenials = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "policy_denials_total",
+		Help:      "Number of cypher commands denied by the access policy, per kind of denied element.",
+	}, []string{"kind"})
+	transactionDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "neo4j_transaction_duration_seconds",
+		Help:      "Duration of the neo4j transactions, per operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	rows = promauto.With(Registry).NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cypher_rows_returned",
+		Help:      "Number of rows returned by the cypher commands.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	})
+	sessionsInUse = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "neo4j_sessions_in_use",
+		Help:      "Number of neo4j sessions in use, each holding a connection of the driver pool while it runs a query.",
+	})
+	poolSize = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "neo4j_pool_max_size",
+		Help:      "Maximum number of connections of the neo4j driver pool.",
+	})
+	breakerState = promauto.With(Registry).NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "neo4j_breaker_state",
+		Help:      "State of the circuit breaker around the neo4j calls, 1 for the current state (closed, open or half-open).",
+	}, []string{"state"})
+)
+
+func init() {
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// ObserveRequest records an API request
+func ObserveRequest(operation string, code int, duration time.Duration) {
+	requests.WithLabelValues(operation, strconv.Itoa(code)).Inc()
+	requestDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// ParseFailure records a cypher command failing to parse
+func ParseFailure(kind string) {
+	parseFailures.WithLabelValues(kind).Inc()
+}
+
+// PolicyDenial records a cypher command denied by the access policy
+func PolicyDenial(kind string) {
+	policyDenials.WithLabelValues(kind).Inc()
+}
+
+// ObserveTransaction records the duration of a neo4j transaction
+func ObserveTransaction(operation string, duration time.Duration) {
+	transactionDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// ObserveRows records the number of rows returned by a cypher command
+func ObserveRows(n int) {
+	rows.Observe(float64(n))
+}
+
+// SessionOpened records a neo4j session being opened
+func SessionOpened() {
+	sessionsInUse.Inc()
+}
+
+// SessionClosed records a neo4j session being closed
+func SessionClosed() {
+	sessionsInUse.Dec()
+}
+
+// SetPoolSize records the maximum number of connections of the neo4j driver pool, the saturation of the pool being
+// the sessions in use divided by it
+func SetPoolSize(size int) {
+	poolSize.Set(float64(size))
+}
+
+// breakerStates are the states of the circuit breaker, all reported not to miss the transitions
+var breakerStates = []string{"closed", "open", "half-open"}
+
+// SetBreakerState records the state of the circuit breaker around the neo4j calls
+func SetBreakerState(state string) {
+	for _, s := range breakerStates {
+		if s == state {
+			breakerState.WithLabelValues(s).Set(1)
+		} else {
+			breakerState.WithLabelValues(s).Set(0)
+		}
+	}
+}
+
+// Middleware is a negroni middleware serving the metrics on its path
+type Middleware struct {
+	path    string
+	handler http.Handler
+}
+
+// NewMiddleware returns the middleware serving the metrics on path
+func NewMiddleware(path string) *Middleware {
+	return &Middleware{
+		path:    path,
+		handler: promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}),
+	}
+}
+
+func (m *Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.URL.Path == m.path && r.Method == http.MethodGet {
+		m.handler.ServeHTTP(rw, r)
+		return
+	}
+	next(rw, r)
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape returns the metrics as exposed by the middleware
+func scrape(t *testing.T, m *Middleware) string {
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil), func(http.ResponseWriter, *http.Request) {
+		t.Error("the metrics path must not reach the next handler")
+	})
+	assert.Equal(t, http.StatusOK, rw.Code)
+	return rw.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMiddleware("/metrics")
+
+	ObserveRequest("doCypher", 200, 20*time.Millisecond)
+	ObserveRequest("doCypher", 422, time.Millisecond)
+	ParseFailure("syntax")
+	PolicyDenial("label")
+	ObserveTransaction("doCypher", 15*time.Millisecond)
+	ObserveRows(38)
+	SetPoolSize(10)
+	SessionOpened()
+	SessionOpened()
+	SessionClosed()
+	SetBreakerState("open")
+
+	body := scrape(t, m)
+	for _, line := range []string{
+		`lexneo4j_requests_total{code="200",operation="doCypher"} 1`,
+		`lexneo4j_requests_total{code="422",operation="doCypher"} 1`,
+		`lexneo4j_request_duration_seconds_count{operation="doCypher"} 2`,
+		`lexneo4j_cypher_parse_failures_total{kind="syntax"} 1`,
+		`lexneo4j_policy_denials_total{kind="label"} 1`,
+		`lexneo4j_neo4j_transaction_duration_seconds_count{operation="doCypher"} 1`,
+		`lexneo4j_cypher_rows_returned_sum 38`,
+		`lexneo4j_neo4j_sessions_in_use 1`,
+		`lexneo4j_neo4j_pool_max_size 10`,
+		`lexneo4j_neo4j_breaker_state{state="closed"} 0`,
+		`lexneo4j_neo4j_breaker_state{state="open"} 1`,
+		`go_goroutines`,
+	} {
+		assert.Contains(t, body, line)
+	}
+
+	t.Run("other paths", func(t *testing.T) {
+		called := false
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/health", nil), func(http.ResponseWriter, *http.Request) {
+			called = true
+		})
+		assert.True(t, called)
+	})
+}
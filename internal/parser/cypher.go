@@ -1,6 +1,9 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 const (
 	REL_BOTH int = iota
@@ -11,13 +14,74 @@ const (
 type CypherQuery struct {
 	MatchNode    CypherNode
 	Relationship *CypherRelationShip
-	Return       CypherReturn
+	// Where is the WHERE clause of the leading MATCH
+	Where CypherExpression
+	// Matches are the MATCH / OPTIONAL MATCH clauses following the leading MATCH
+	Matches []CypherMatch
+	// With are the next stages of the query pipeline
+	With   []CypherWith
+	Return CypherReturn
+	Limit  *int
+	// Unions are the queries combined with this one
+	Unions []CypherUnion
+}
+
+// CypherUnion is a query combined with UNION or UNION ALL, i.e. "UNION ALL MATCH (n:Person) RETURN n.name AS name"
+type CypherUnion struct {
+	All   bool
+	Query CypherQuery
+	// Pos is the position of the UNION keyword in the query, in runes
+	Pos int
+}
+
+// CypherMatch is a reading clause following the leading MATCH, i.e. "OPTIONAL MATCH (m)<-[:DIRECTED]-(d)"
+type CypherMatch struct {
+	Optional     bool
+	Node         CypherNode
+	Relationship *CypherRelationShip
+	Where        CypherExpression
+}
+
+// CypherWith is a WITH clause, starting a new stage of the query: only the projected variables are still in scope
+// after it, i.e. "WITH a, count(m) AS movies WHERE movies > 3 MATCH (a)-->(o)"
+type CypherWith struct {
+	Projections CypherReturn
+	Where       CypherExpression
+	// Matches are the MATCH / OPTIONAL MATCH clauses following the WITH
+	Matches []CypherMatch
 }
 
 type CypherRelationShip struct {
 	Direction int
 	Props     *CypherNode
-	Target    CypherNode
+	// Hops is the length of a variable-length relationship (nil for a single hop)
+	Hops   *CypherHops
+	Target CypherNode
+}
+
+// CypherHops is the length of a variable-length relationship, i.e. "*", "*2", "*1..3", "*..3" or "*2..".
+// A nil Max means the relationship is unbounded.
+type CypherHops struct {
+	Min *int
+	Max *int
+}
+
+func (h *CypherHops) ToString() string {
+	str := "*"
+	if h.Min != nil {
+		str += fmt.Sprintf("%d", *h.Min)
+		if h.Max != nil && *h.Max == *h.Min {
+			return str
+		}
+	}
+	if h.Min == nil && h.Max == nil {
+		return str
+	}
+	str += ".."
+	if h.Max != nil {
+		str += fmt.Sprintf("%d", *h.Max)
+	}
+	return str
 }
 
 type CypherNode struct {
@@ -28,20 +92,69 @@ type CypherNode struct {
 
 type CypherReturn []CypherVariableReturn
 
+// CypherVariableReturn is a projected element of a WITH or RETURN clause, i.e. "a.name", "count(DISTINCT m) AS movies"
 type CypherVariableReturn struct {
 	VariableName string
 	Property     *string
+	// Function is the (lower case) function applied to the variable, if any
+	Function *string
+	Distinct bool
+	Alias    *string
+	// Pos is the position of the element in the query, in runes
+	Pos int
 }
 
 func (r *CypherVariableReturn) ToString() string {
-	if r.Property == nil {
-		return r.VariableName
-	} else {
-		return fmt.Sprintf("%s.%s", r.VariableName, *r.Property)
+	str := r.VariableName
+	if r.Property != nil {
+		str = fmt.Sprintf("%s.%s", r.VariableName, *r.Property)
 	}
+	if r.Function != nil {
+		if r.Distinct {
+			str = "DISTINCT " + str
+		}
+		str = fmt.Sprintf("%s(%s)", *r.Function, str)
+	}
+	if r.Alias != nil {
+		str += " AS " + *r.Alias
+	}
+	return str
+}
+
+// Name returns the name of the column (or of the variable for a WITH) produced by the element
+func (r *CypherVariableReturn) Name() string {
+	if r.Alias != nil {
+		return *r.Alias
+	}
+	return r.ToString()
+}
+
+func (r CypherReturn) ToString() string {
+	str := ""
+	for i, ret := range r {
+		if i > 0 {
+			str += ","
+		}
+		str += ret.ToString()
+	}
+	return str
 }
 
 func (n *CypherNode) ToStringWithTenant(tenant string) string {
+	return n.toString(&tenant)
+}
+
+func (n *CypherNode) ToString() string {
+	return n.toString(nil)
+}
+
+// toString renders the node content, adding the tenant property if tenant is not nil
+func (n *CypherNode) toString(tenant *string) string {
+	return n.label() + n.properties(tenant)
+}
+
+// label renders the variable and the label of the node, i.e. "a:Person"
+func (n *CypherNode) label() string {
 	str := ""
 	if n.VariableName != nil {
 		str = *n.VariableName
@@ -49,119 +162,147 @@ func (n *CypherNode) ToStringWithTenant(tenant string) string {
 	if n.TypeName != nil {
 		str += ":" + *n.TypeName
 	}
+	return str
+}
+
+// properties renders the properties of the node, i.e. "{foo:'bar'}", adding the tenant property if tenant is not nil
+func (n *CypherNode) properties(tenant *string) string {
 	props := make(map[string]string)
 	for k, v := range n.Props {
 		props[k] = v
 	}
-	props["tenant"] = tenant
+	if tenant != nil {
+		props["tenant"] = *tenant
+	}
+	if len(props) == 0 {
+		return ""
+	}
 
-	str += "{"
-	firstProp := true
-	for k, v := range props {
-		if !firstProp {
+	// sort the properties to get a stable rendering
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	str := "{"
+	for i, k := range keys {
+		if i > 0 {
 			str += ","
 		}
-		str += fmt.Sprintf("%s:'%s'", k, v)
-		firstProp = false
+		str += fmt.Sprintf("%s:%s", k, quote(props[k]))
 	}
 	str += "}"
 
 	return str
 }
 
-func (q *CypherQuery) ToStringWithTenant(tenant string) string {
-	str := "MATCH "
-	str += fmt.Sprintf("(%s)", q.MatchNode.ToStringWithTenant(tenant))
-
-	if q.Relationship != nil {
-		if q.Relationship.Direction == REL_FROM {
-			str += "<-"
-		} else {
-			str += "-"
-		}
-		if q.Relationship.Props != nil {
-			str += fmt.Sprintf("[%s]", q.Relationship.Props.ToStringWithTenant(tenant))
-		}
-		if q.Relationship.Direction == REL_TO {
-			str += "->"
-		} else {
-			str += "-"
-		}
-		str += fmt.Sprintf("(%s)", q.Relationship.Target.ToStringWithTenant(tenant))
+// toString renders the relationship and its target node, i.e. "-[r]->(o)"
+func (r *CypherRelationShip) toString(tenant *string) string {
+	str := ""
+	if r.Direction == REL_FROM {
+		str += "<-"
+	} else {
+		str += "-"
 	}
-
-	if q.Return != nil {
-		str += " RETURN "
-		firstRet := true
-		for _, ret := range q.Return {
-			if !firstRet {
-				str += ","
-			}
-			str += ret.ToString()
-			firstRet = false
+	if r.Props != nil {
+		hops := ""
+		if r.Hops != nil {
+			hops = r.Hops.ToString()
 		}
+		str += fmt.Sprintf("[%s%s%s]", r.Props.label(), hops, r.Props.properties(tenant))
 	}
+	if r.Direction == REL_TO {
+		str += "->"
+	} else {
+		str += "-"
+	}
+	str += fmt.Sprintf("(%s)", r.Target.toString(tenant))
 	return str
 }
 
-func (n *CypherNode) ToString() string {
-	str := ""
-	if n.VariableName != nil {
-		str = *n.VariableName
+// toString renders the clause. The tenant is set inside the pattern itself (and not in a WHERE clause)
+// so that an OPTIONAL MATCH stays optional and is not turned into an inner join
+func (m *CypherMatch) toString(tenant *string) string {
+	str := "MATCH "
+	if m.Optional {
+		str = "OPTIONAL MATCH "
 	}
-	if n.TypeName != nil {
-		str += ":" + *n.TypeName
+	str += fmt.Sprintf("(%s)", m.Node.toString(tenant))
+	if m.Relationship != nil {
+		str += m.Relationship.toString(tenant)
 	}
-	props := make(map[string]string)
-	for k, v := range n.Props {
-		props[k] = v
+	if m.Where != nil {
+		str += " WHERE " + m.Where.toString(tenant)
 	}
-
-	str += "{"
-	firstProp := true
-	for k, v := range props {
-		if !firstProp {
-			str += ","
-		}
-		str += fmt.Sprintf("%s:'%s'", k, v)
-		firstProp = false
-	}
-	str += "}"
-
 	return str
 }
 
+func (q *CypherQuery) ToStringWithTenant(tenant string) string {
+	return q.toString(&tenant)
+}
+
 func (q *CypherQuery) ToString() string {
-	str := "MATCH "
-	str += fmt.Sprintf("(%s)", q.MatchNode.ToString())
+	return q.toString(nil)
+}
 
-	if q.Relationship != nil {
-		if q.Relationship.Direction == REL_FROM {
-			str += "<-"
-		} else {
-			str += "-"
-		}
-		if q.Relationship.Props != nil {
-			str += fmt.Sprintf("[%s]", q.Relationship.Props.ToString())
+func (q *CypherQuery) toString(tenant *string) string {
+	first := CypherMatch{Node: q.MatchNode, Relationship: q.Relationship, Where: q.Where}
+	str := first.toString(tenant)
+
+	for _, m := range q.Matches {
+		str += " " + m.toString(tenant)
+	}
+
+	for _, w := range q.With {
+		str += " WITH " + w.Projections.ToString()
+		if w.Where != nil {
+			str += " WHERE " + w.Where.toString(tenant)
 		}
-		if q.Relationship.Direction == REL_TO {
-			str += "->"
-		} else {
-			str += "-"
+		for _, m := range w.Matches {
+			str += " " + m.toString(tenant)
 		}
-		str += fmt.Sprintf("(%s)", q.Relationship.Target.ToString())
 	}
 
 	if q.Return != nil {
-		str += " RETURN "
-		firstRet := true
-		for _, ret := range q.Return {
-			if !firstRet {
-				str += ","
-			}
-			str += ret.ToString()
-			firstRet = false
+		str += " RETURN " + q.Return.ToString()
+	}
+	if q.Limit != nil {
+		str += fmt.Sprintf(" LIMIT %d", *q.Limit)
+	}
+
+	for _, u := range q.Unions {
+		str += " UNION "
+		if u.All {
+			str += "ALL "
 		}
+		str += u.Query.toString(tenant)
 	}
 	return str
 }
+
+// UsesRegex returns true if a WHERE clause of the query (or of a query it is combined with) uses a regular expression
+func (q *CypherQuery) UsesRegex() bool {
+	uses := false
+	for _, query := range q.Queries() {
+		for _, where := range query.WhereExpressions() {
+			WalkExpression(where, func(e CypherExpression) bool {
+				if binary, ok := e.(*CypherBinaryExpression); ok && binary.Operator == "=~" {
+					uses = true
+				}
+				return true
+			})
+		}
+	}
+	return uses
+}
+
+// CapRows limits the number of rows returned by the query, and by each query it is combined with
+func (q *CypherQuery) CapRows(max int) {
+	if q.Limit == nil || *q.Limit > max {
+		q.Limit = &max
+	}
+	for i := range q.Unions {
+		q.Unions[i].Query.CapRows(max)
+	}
+}
@@ -0,0 +1,47 @@
+package parser
+
+import "fmt"
+
+// ParseError is a syntax error, positioned on the token where the parsing failed
+type ParseError struct {
+	Message string
+	// Pos is the position of the token in the query, in runes
+	Pos int
+	// Line and Column of the token, starting at 1
+	Line   int
+	Column int
+	// Semantic is true for an error found once the query is parsed, i.e. an undefined variable
+	Semantic bool
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s (line %d, column %d)", e.Message, e.Line, e.Column)
+}
+
+// newParseError positions an error on the last scanned token
+func (p *Parser) newParseError(err error) *ParseError {
+	return p.locate(&ParseError{Message: err.Error(), Pos: p.pos})
+}
+
+// newSemanticError positions an error found once the query is parsed on the token at pos
+func newSemanticError(pos int, format string, a ...interface{}) *ParseError {
+	return &ParseError{Message: fmt.Sprintf(format, a...), Pos: pos, Semantic: true}
+}
+
+// locate sets the line and the column of the error from its position
+func (p *Parser) locate(e *ParseError) *ParseError {
+	line, column := 1, 1
+	for i, ch := range []rune(p.raw) {
+		if i == e.Pos {
+			break
+		}
+		if ch == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	e.Line, e.Column = line, column
+	return e
+}
@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CypherExpression is an expression of a WHERE clause, i.e. "movies > 3 AND a.name <> 'Tom'"
+type CypherExpression interface {
+	toString(tenant *string) string
+}
+
+// CypherBinaryExpression is a boolean (AND, OR), comparison (=, <>, <, >, <=, >=) or predicate
+// (IN, STARTS WITH, ENDS WITH, CONTAINS, =~) expression
+type CypherBinaryExpression struct {
+	Operator string
+	Left     CypherExpression
+	Right    CypherExpression
+}
+
+// CypherNotExpression is a negated expression, i.e. "NOT a.name = 'Tom'"
+type CypherNotExpression struct {
+	Expression CypherExpression
+}
+
+// CypherIsNullExpression is a null check, i.e. "a.born IS NOT NULL"
+type CypherIsNullExpression struct {
+	Expression CypherExpression
+	Not        bool
+}
+
+// CypherParenthesisExpression is an expression surrounded by parenthesis, i.e. "(a OR b)"
+type CypherParenthesisExpression struct {
+	Expression CypherExpression
+}
+
+// CypherPropertyExpression is a variable, or a property of a variable, i.e. "a" or "a.name"
+type CypherPropertyExpression struct {
+	VariableName string
+	Property     *string
+	// Pos is the position of the variable in the query, in runes
+	Pos int
+}
+
+// CypherLiteralExpression is a literal value, i.e. 'Tom', 3, true or null
+type CypherLiteralExpression struct {
+	Value string
+	// Quoted is true for a string literal
+	Quoted bool
+}
+
+// CypherListExpression is a list, i.e. "[1999, 2003]"
+type CypherListExpression struct {
+	Items []CypherExpression
+}
+
+// CypherPatternExpression is a pattern predicate, i.e. "(p)-[:DIRECTED]->()". It cannot introduce new variables.
+type CypherPatternExpression struct {
+	Node         CypherNode
+	Relationship *CypherRelationShip
+	// Pos is the position of the pattern in the query, in runes
+	Pos int
+}
+
+// CypherExistsExpression is an EXISTS subquery, i.e. "EXISTS { MATCH (p)-[:DIRECTED]->(m) WHERE m.released > 2000 }".
+// The variables it introduces are only in scope inside the subquery.
+type CypherExistsExpression struct {
+	Matches []CypherMatch
+}
+
+func (e *CypherBinaryExpression) toString(tenant *string) string {
+	return fmt.Sprintf("%s %s %s", e.Left.toString(tenant), e.Operator, e.Right.toString(tenant))
+}
+
+func (e *CypherNotExpression) toString(tenant *string) string {
+	return "NOT " + e.Expression.toString(tenant)
+}
+
+func (e *CypherIsNullExpression) toString(tenant *string) string {
+	if e.Not {
+		return e.Expression.toString(tenant) + " IS NOT NULL"
+	}
+	return e.Expression.toString(tenant) + " IS NULL"
+}
+
+func (e *CypherParenthesisExpression) toString(tenant *string) string {
+	return fmt.Sprintf("(%s)", e.Expression.toString(tenant))
+}
+
+func (e *CypherPropertyExpression) toString(tenant *string) string {
+	if e.Property == nil {
+		return e.VariableName
+	}
+	return fmt.Sprintf("%s.%s", e.VariableName, *e.Property)
+}
+
+func (e *CypherLiteralExpression) toString(tenant *string) string {
+	if e.Quoted {
+		return quote(e.Value)
+	}
+	return e.Value
+}
+
+func (e *CypherListExpression) toString(tenant *string) string {
+	items := make([]string, len(e.Items))
+	for i, item := range e.Items {
+		items[i] = item.toString(tenant)
+	}
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+// toString renders the pattern, with the tenant set inside it like in a MATCH clause
+func (e *CypherPatternExpression) toString(tenant *string) string {
+	str := fmt.Sprintf("(%s)", e.Node.toString(tenant))
+	if e.Relationship != nil {
+		str += e.Relationship.toString(tenant)
+	}
+	return str
+}
+
+func (e *CypherExistsExpression) toString(tenant *string) string {
+	str := "EXISTS {"
+	for _, m := range e.Matches {
+		str += " " + m.toString(tenant)
+	}
+	return str + " }"
+}
+
+// quote renders a string literal, escaping what would allow to escape from it
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// WalkExpression calls fn for the expression and, as long as fn returns true, for each of its sub expressions
+// (including the WHERE clauses of EXISTS subqueries)
+func WalkExpression(e CypherExpression, fn func(CypherExpression) bool) {
+	if !fn(e) {
+		return
+	}
+	switch e := e.(type) {
+	case *CypherBinaryExpression:
+		WalkExpression(e.Left, fn)
+		WalkExpression(e.Right, fn)
+	case *CypherNotExpression:
+		WalkExpression(e.Expression, fn)
+	case *CypherIsNullExpression:
+		WalkExpression(e.Expression, fn)
+	case *CypherParenthesisExpression:
+		WalkExpression(e.Expression, fn)
+	case *CypherListExpression:
+		for _, item := range e.Items {
+			WalkExpression(item, fn)
+		}
+	case *CypherExistsExpression:
+		for _, m := range e.Matches {
+			if m.Where != nil {
+				WalkExpression(m.Where, fn)
+			}
+		}
+	}
+}
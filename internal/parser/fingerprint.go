@@ -0,0 +1,222 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fingerprint returns the canonical form of the query without its literal values, i.e.
+// "MATCH (v1:Movie{released:?}) WHERE v1.title STARTS WITH ? RETURN v1.title LIMIT ?", so that the queries differing
+// only by their values, their variable names (and their tenant) share the same fingerprint. It is built from the
+// parsed query: the labels, relationship types, patterns and returned columns are kept, the variables are renamed by
+// order of appearance, and a list of literals is reduced to a single ?.
+func (q *CypherQuery) Fingerprint() string {
+	str := ""
+	for i, query := range q.Queries() {
+		if i > 0 {
+			str += " UNION "
+			if q.Unions[i-1].All {
+				str += "ALL "
+			}
+		}
+		// each query of a UNION has its own variables
+		f := fingerprinter{names: map[string]string{}}
+		str += f.query(query)
+	}
+	return str
+}
+
+// fingerprinter renders the fingerprint of a single query, renaming its variables
+type fingerprinter struct {
+	names map[string]string
+}
+
+// variable returns the name of a variable in the fingerprint: v1 for the first variable of the query, v2 for the
+// second one, etc.
+func (f *fingerprinter) variable(name string) string {
+	if name == "*" {
+		return name
+	}
+	if renamed, ok := f.names[name]; ok {
+		return renamed
+	}
+	renamed := fmt.Sprintf("v%d", len(f.names)+1)
+	f.names[name] = renamed
+	return renamed
+}
+
+// query renders a single query, without the queries combined with it
+func (f *fingerprinter) query(q *CypherQuery) string {
+	str := f.match(&CypherMatch{Node: q.MatchNode, Relationship: q.Relationship, Where: q.Where})
+	for i := range q.Matches {
+		str += " " + f.match(&q.Matches[i])
+	}
+	for _, w := range q.With {
+		str += " WITH " + f.projections(w.Projections, true)
+		if w.Where != nil {
+			str += " WHERE " + f.expression(w.Where)
+		}
+		for i := range w.Matches {
+			str += " " + f.match(&w.Matches[i])
+		}
+	}
+	if q.Return != nil {
+		str += " RETURN " + f.projections(q.Return, false)
+	}
+	if q.Limit != nil {
+		str += " LIMIT ?"
+	}
+	return str
+}
+
+func (f *fingerprinter) match(m *CypherMatch) string {
+	str := "MATCH "
+	if m.Optional {
+		str = "OPTIONAL MATCH "
+	}
+	str += f.pattern(&m.Node, m.Relationship)
+	if m.Where != nil {
+		str += " WHERE " + f.expression(m.Where)
+	}
+	return str
+}
+
+// pattern renders a node, and the relationship to its target if any, i.e. "(v1:Person)-[:KNOWS*?..?]->(v2)"
+func (f *fingerprinter) pattern(node *CypherNode, rel *CypherRelationShip) string {
+	str := "(" + f.node(node) + ")"
+	if rel == nil {
+		return str
+	}
+	if rel.Direction == REL_FROM {
+		str += "<-"
+	} else {
+		str += "-"
+	}
+	if rel.Props != nil {
+		hops := ""
+		if rel.Hops != nil {
+			hops = f.hops(rel.Hops)
+		}
+		str += "[" + f.node(rel.Props) + hops + "]"
+	}
+	if rel.Direction == REL_TO {
+		str += "->"
+	} else {
+		str += "-"
+	}
+	return str + "(" + f.node(&rel.Target) + ")"
+}
+
+// node renders the variable, the label and the property keys of a node (or of a relationship), i.e.
+// "v1:Movie{released:?}"
+func (f *fingerprinter) node(n *CypherNode) string {
+	str := ""
+	if n.VariableName != nil {
+		str = f.variable(*n.VariableName)
+	}
+	if n.TypeName != nil {
+		str += ":" + *n.TypeName
+	}
+	if len(n.Props) == 0 {
+		return str
+	}
+	keys := make([]string, 0, len(n.Props))
+	for k := range n.Props {
+		keys = append(keys, k+":?")
+	}
+	sort.Strings(keys)
+	return str + "{" + strings.Join(keys, ",") + "}"
+}
+
+func (f *fingerprinter) hops(h *CypherHops) string {
+	switch {
+	case h.Min == nil && h.Max == nil:
+		return "*"
+	case h.Min != nil && h.Max != nil && *h.Min == *h.Max:
+		return "*?"
+	}
+	str := "*"
+	if h.Min != nil {
+		str += "?"
+	}
+	str += ".."
+	if h.Max != nil {
+		str += "?"
+	}
+	return str
+}
+
+// projections renders the elements of a WITH clause, whose aliases are variables, or of a RETURN clause, whose
+// aliases are the names of the returned columns
+func (f *fingerprinter) projections(r CypherReturn, with bool) string {
+	elements := make([]string, len(r))
+	for i, ret := range r {
+		str := f.variable(ret.VariableName)
+		if ret.Property != nil {
+			str += "." + *ret.Property
+		}
+		if ret.Function != nil {
+			if ret.Distinct {
+				str = "DISTINCT " + str
+			}
+			str = *ret.Function + "(" + str + ")"
+		}
+		if ret.Alias != nil {
+			alias := *ret.Alias
+			if with {
+				alias = f.variable(alias)
+			}
+			str += " AS " + alias
+		}
+		elements[i] = str
+	}
+	return strings.Join(elements, ",")
+}
+
+func (f *fingerprinter) expression(e CypherExpression) string {
+	switch e := e.(type) {
+	case *CypherBinaryExpression:
+		return fmt.Sprintf("%s %s %s", f.expression(e.Left), e.Operator, f.expression(e.Right))
+	case *CypherNotExpression:
+		return "NOT " + f.expression(e.Expression)
+	case *CypherIsNullExpression:
+		if e.Not {
+			return f.expression(e.Expression) + " IS NOT NULL"
+		}
+		return f.expression(e.Expression) + " IS NULL"
+	case *CypherParenthesisExpression:
+		return "(" + f.expression(e.Expression) + ")"
+	case *CypherPropertyExpression:
+		str := f.variable(e.VariableName)
+		if e.Property != nil {
+			str += "." + *e.Property
+		}
+		return str
+	case *CypherLiteralExpression:
+		if !e.Quoted && e.Value == "null" {
+			return "null"
+		}
+		return "?"
+	case *CypherListExpression:
+		items := make([]string, len(e.Items))
+		literals := true
+		for i, item := range e.Items {
+			items[i] = f.expression(item)
+			literals = literals && items[i] == "?"
+		}
+		if literals && len(items) > 0 {
+			return "[?]"
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case *CypherPatternExpression:
+		return f.pattern(&e.Node, e.Relationship)
+	case *CypherExistsExpression:
+		str := "EXISTS {"
+		for i := range e.Matches {
+			str += " " + f.match(&e.Matches[i])
+		}
+		return str + " }"
+	}
+	return ""
+}
@@ -10,6 +10,8 @@ import (
 // Lexer represents a lexical scanner.
 type Lexer struct {
 	r *bufio.Reader
+	// pos is the position of the next rune to read
+	pos int
 }
 
 // NewLexerFromString returns a Lexer for the provided string.
@@ -22,53 +24,84 @@ func NewLexer(r io.Reader) *Lexer {
 	return &Lexer{r: bufio.NewReader(r)}
 }
 
-// Scan returns the next token and literal Value.
+// Scan returns the next token and literal Value, with its position.
 func (s *Lexer) Scan() TokenInfo {
+	pos := s.pos
+	tok := s.scan()
+	tok.Pos = pos
+	return tok
+}
+
+// scan returns the next token and literal Value.
+func (s *Lexer) scan() TokenInfo {
 	// Read the next rune.
 	ch := s.read()
 	if ch == eof {
-		return TokenInfo{EOF, ""}
+		return TokenInfo{Token: EOF, Literal: ""}
 	}
 
 	// Find all 1 or 2 length tokens
 	if ch == '<' {
 		next := s.read()
-		if next == '-' {
+		switch next {
+		case '-':
 			// Don't unread, found a 2 length token
-			return TokenInfo{FROM_RELATIONSHIP, "<-"}
+			return TokenInfo{Token: FROM_RELATIONSHIP, Literal: "<-"}
+		case '>':
+			return TokenInfo{Token: NOT_EQUAL, Literal: "<>"}
+		case '=':
+			return TokenInfo{Token: LESS_OR_EQUAL, Literal: "<="}
+		}
+		s.unread()
+		return TokenInfo{Token: LESS_THAN, Literal: "<"}
+	}
+	if ch == '=' {
+		next := s.read()
+		if next == '~' {
+			return TokenInfo{Token: REGEX_MATCH, Literal: "=~"}
 		}
 		s.unread()
+		return TokenInfo{Token: EQUAL, Literal: "="}
+	}
+	if ch == '>' {
+		next := s.read()
+		if next == '=' {
+			return TokenInfo{Token: GREATER_OR_EQUAL, Literal: ">="}
+		}
 		s.unread()
+		return TokenInfo{Token: GREATER_THAN, Literal: ">"}
 	}
 	if ch == '-' {
 		next := s.read()
 		if next == '>' {
 			// Don't unread, found a 2 length token
-			return TokenInfo{TO_RELATIONSHIP, "->"}
+			return TokenInfo{Token: TO_RELATIONSHIP, Literal: "->"}
 		}
 		s.unread()
-		return TokenInfo{RELATIONSHIP, "-"}
+		return TokenInfo{Token: RELATIONSHIP, Literal: "-"}
 	}
 
 	switch {
 	case ch == '[':
-		return TokenInfo{OPEN_BRACKET, string(ch)}
+		return TokenInfo{Token: OPEN_BRACKET, Literal: string(ch)}
 	case ch == ']':
-		return TokenInfo{CLOSED_BRACKET, string(ch)}
+		return TokenInfo{Token: CLOSED_BRACKET, Literal: string(ch)}
 	case ch == '{':
-		return TokenInfo{OPEN_CURLYBRACKET, string(ch)}
+		return TokenInfo{Token: OPEN_CURLYBRACKET, Literal: string(ch)}
 	case ch == '}':
-		return TokenInfo{CLOSED_CURLYBRACKET, string(ch)}
+		return TokenInfo{Token: CLOSED_CURLYBRACKET, Literal: string(ch)}
 	case ch == '(':
-		return TokenInfo{OPEN_PARENTHESIS, string(ch)}
+		return TokenInfo{Token: OPEN_PARENTHESIS, Literal: string(ch)}
 	case ch == ')':
-		return TokenInfo{CLOSED_PARENTHESIS, string(ch)}
+		return TokenInfo{Token: CLOSED_PARENTHESIS, Literal: string(ch)}
 	case ch == ':':
-		return TokenInfo{DOUBLECOLON, string(ch)}
+		return TokenInfo{Token: DOUBLECOLON, Literal: string(ch)}
 	case ch == ',':
-		return TokenInfo{COMMA, string(ch)}
+		return TokenInfo{Token: COMMA, Literal: string(ch)}
 	case ch == '.':
-		return TokenInfo{DOT, string(ch)}
+		return TokenInfo{Token: DOT, Literal: string(ch)}
+	case ch == '*':
+		return TokenInfo{Token: ASTERISK, Literal: string(ch)}
 	case isWhitespace(ch):
 		s.unread()
 		return s.scanWhitespace()
@@ -95,7 +128,7 @@ func (s *Lexer) scanWhitespace() TokenInfo {
 		}
 	}
 
-	return TokenInfo{WS, ""}
+	return TokenInfo{Token: WS, Literal: ""}
 }
 
 // scanKeyword consumes the current rune and all contiguous text runes.
@@ -120,6 +153,11 @@ func (s *Lexer) scanKeyword() TokenInfo {
 		if ch == '\'' && quotedString {
 			break
 		}
+		// An escaped char in a quoted string, as written by quote.
+		if ch == '\\' && quotedString {
+			buf.WriteString(unescape(s.read()))
+			continue
+		}
 		// Break if we hit whitespace or a special char and we're not in a quoted string.
 		if (isWhitespace(ch) || isSpecialChar(ch)) && !quotedString {
 			s.unread()
@@ -129,19 +167,74 @@ func (s *Lexer) scanKeyword() TokenInfo {
 		buf.WriteRune(ch)
 	}
 
+	// A quoted string is never a keyword.
+	if quotedString {
+		return TokenInfo{Token: STRING, Literal: buf.String(), Quoted: true}
+	}
+
 	// If the string matches a keyword then return that keyword.
 	switch strings.ToLower(buf.String()) {
 	case "match":
-		return TokenInfo{MATCH, "MATCH"}
+		return TokenInfo{Token: MATCH, Literal: "MATCH"}
 	case "return":
-		return TokenInfo{RETURN, "RETURN"}
+		return TokenInfo{Token: RETURN, Literal: "RETURN"}
 	case "where":
-		return TokenInfo{WHERE, "WHERE"}
+		return TokenInfo{Token: WHERE, Literal: "WHERE"}
 	case "not":
-		return TokenInfo{NOT, "NOT"}
+		return TokenInfo{Token: NOT, Literal: "NOT"}
+	case "optional":
+		return TokenInfo{Token: OPTIONAL, Literal: "OPTIONAL"}
+	case "with":
+		return TokenInfo{Token: WITH, Literal: "WITH"}
+	case "as":
+		return TokenInfo{Token: AS, Literal: "AS"}
+	case "distinct":
+		return TokenInfo{Token: DISTINCT, Literal: "DISTINCT"}
+	case "and":
+		return TokenInfo{Token: AND, Literal: "AND"}
+	case "or":
+		return TokenInfo{Token: OR, Literal: "OR"}
+	case "limit":
+		return TokenInfo{Token: LIMIT, Literal: "LIMIT"}
+	case "union":
+		return TokenInfo{Token: UNION, Literal: "UNION"}
+	case "all":
+		return TokenInfo{Token: ALL, Literal: "ALL"}
+	case "in":
+		return TokenInfo{Token: IN, Literal: "IN"}
+	case "starts":
+		return TokenInfo{Token: STARTS, Literal: "STARTS"}
+	case "ends":
+		return TokenInfo{Token: ENDS, Literal: "ENDS"}
+	case "contains":
+		return TokenInfo{Token: CONTAINS, Literal: "CONTAINS"}
+	case "is":
+		return TokenInfo{Token: IS, Literal: "IS"}
+	case "null":
+		return TokenInfo{Token: NULL, Literal: "NULL"}
+	case "exists":
+		return TokenInfo{Token: EXISTS, Literal: "EXISTS"}
 	}
 
-	return TokenInfo{STRING, buf.String()}
+	return TokenInfo{Token: STRING, Literal: buf.String()}
+}
+
+// unescape returns the char escaped by a backslash in a quoted string, i.e. ' for \' or a newline for \n. The unknown
+// escapes are kept as is.
+func unescape(ch rune) string {
+	switch ch {
+	case '\'', '"', '\\':
+		return string(ch)
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case eof:
+		return `\`
+	}
+	return `\` + string(ch)
 }
 
 // read reads the next rune from the buffered reader.
@@ -151,6 +244,7 @@ func (s *Lexer) read() rune {
 	if err != nil {
 		return eof
 	}
+	s.pos++
 	return ch
 }
 
@@ -158,14 +252,16 @@ func (s *Lexer) read() rune {
 func (s *Lexer) unread() {
 	// Unread can error if we have previously not called read, this is not dangerous (no data mutation) and returning
 	// error here would unnecessarily complicate the code.
-	_ = s.r.UnreadRune()
+	if s.r.UnreadRune() == nil {
+		s.pos--
+	}
 }
 
 // isWhitespace returns true if the rune is a space, tab, or newline.
 func isWhitespace(ch rune) bool { return ch == ' ' || ch == '\t' || ch == '\n' }
 
 func isSpecialChar(ch rune) bool {
-	specialChar := []rune{'(', ')', '{', '}', '[', ']', '.', ':', ','}
+	specialChar := []rune{'(', ')', '{', '}', '[', ']', '.', ':', ',', '=', '<', '>', '*'}
 	for _, char := range specialChar {
 		if ch == char {
 			return true
@@ -43,4 +43,28 @@ func TestLexer(t *testing.T) {
 		assert.Equal(t, []Token{STRING, OPEN_CURLYBRACKET, STRING, DOUBLECOLON, STRING, EOF}, tokens)
 		assert.Equal(t, []string{"Person", "{", "b", ":", "c}", ""}, literals)
 	})
+
+	t.Run("scan comparison operators", func(t *testing.T) {
+		s := "a<>b<=c>=d<e>f=g<-h"
+		lexer := NewLexerFromString(s)
+		tokens, _ := lexerHelper(lexer)
+		assert.Equal(t, []Token{STRING, NOT_EQUAL, STRING, LESS_OR_EQUAL, STRING, GREATER_OR_EQUAL, STRING, LESS_THAN, STRING, GREATER_THAN, STRING, EQUAL, STRING, FROM_RELATIONSHIP, STRING, EOF}, tokens)
+	})
+
+	t.Run("scan escaped chars in quotes", func(t *testing.T) {
+		s := `'O\'Brien' 'a\\b' '\n\d'`
+		lexer := NewLexerFromString(s)
+		_, literals := lexerHelper(lexer)
+		assert.Equal(t, []string{"O'Brien", "", `a\b`, "", "\n\\d", ""}, literals)
+	})
+
+	t.Run("quoted string is not a keyword", func(t *testing.T) {
+		s := "'match' match"
+		lexer := NewLexerFromString(s)
+		first := lexer.Scan()
+		assert.Equal(t, STRING, first.Token)
+		assert.True(t, first.Quoted)
+		lexer.Scan()
+		assert.Equal(t, MATCH, lexer.Scan().Token)
+	})
 }
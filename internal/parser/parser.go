@@ -2,7 +2,9 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // Parser represents a parser, including a scanner and the underlying raw input.
@@ -11,6 +13,8 @@ type Parser struct {
 	s   *Lexer
 	raw string
 	buf TokenStack
+	// pos is the position of the last scanned token
+	pos int
 }
 
 // NewParser returns a new instance of Parser.
@@ -19,50 +23,201 @@ func NewParser(s string) *Parser {
 }
 
 // Parse takes the raw string and returns the root node of the AST.
+// A syntax error is returned as a *ParseError, positioned on the token where the parsing failed, and so is a semantic
+// error (i.e. an undefined variable), positioned on the offending token.
 func (p *Parser) Parse() (*CypherQuery, error) {
 	operation, err := p.parseQuery()
 	if err != nil {
-		return nil, err
+		return nil, p.newParseError(err)
+	}
+	if err := operation.validate(); err != nil {
+		return nil, p.locate(err)
 	}
 	return operation, nil
 }
 
-// parseQuery parse stuff like MATCH (n:Person{foo:'bar'}) RETURN n.foo"
+// parseQuery parse stuff like MATCH (n:Person) RETURN n.name AS name UNION MATCH (m:Movie) RETURN m.title AS name"
 func (p *Parser) parseQuery() (*CypherQuery, error) {
+	cypher, err := p.parseSingleQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, lit := p.scanIgnoreWhitespace()
+		switch tok {
+		case EOF:
+			return cypher, nil
+		case UNION:
+			union := CypherUnion{Pos: p.pos}
+			tok, lit = p.scanIgnoreWhitespace()
+			if tok == ALL {
+				union.All = true
+			} else {
+				p.unscan(TokenInfo{Token: tok, Literal: lit})
+			}
+			if len(cypher.Unions) > 0 && cypher.Unions[0].All != union.All {
+				return nil, fmt.Errorf("mixing UNION and UNION ALL is not supported")
+			}
+
+			query, err := p.parseSingleQuery()
+			if err != nil {
+				return nil, err
+			}
+			union.Query = *query
+			cypher.Unions = append(cypher.Unions, union)
+		default:
+			return nil, fmt.Errorf("unexpected '%s' after the RETURN clause", lit)
+		}
+	}
+}
+
+// parseSingleQuery parse stuff like MATCH (n:Person{foo:'bar'}) OPTIONAL MATCH (n)-->(m) WITH n, count(m) AS c RETURN n.foo,c LIMIT 10"
+func (p *Parser) parseSingleQuery() (*CypherQuery, error) {
 	tok, _ := p.scanIgnoreWhitespace()
 	if tok != MATCH {
 		return nil, fmt.Errorf("not able to find a MATCH at the beginning of the expression")
 	}
 
-	node, err := p.parseNode()
+	match, err := p.parseMatch(false)
 	if err != nil {
 		return nil, err
 	}
 
-	cypher := CypherQuery{MatchNode: *node}
+	cypher := CypherQuery{MatchNode: match.Node, Relationship: match.Relationship, Where: match.Where}
+
+	for {
+		tok, lit := p.scanIgnoreWhitespace()
+		switch tok {
+		case OPTIONAL, MATCH:
+			optional := tok == OPTIONAL
+			if optional {
+				tok, lit = p.scanIgnoreWhitespace()
+				if tok != MATCH {
+					return nil, fmt.Errorf("expected MATCH after OPTIONAL. Got %s", lit)
+				}
+			}
+			match, err := p.parseMatch(optional)
+			if err != nil {
+				return nil, err
+			}
+			cypher.addMatch(*match)
+		case WITH:
+			with, err := p.parseWith()
+			if err != nil {
+				return nil, err
+			}
+			cypher.With = append(cypher.With, *with)
+		case RETURN:
+			ret, err := p.parseReturn()
+			if err != nil {
+				return nil, err
+			}
+			cypher.Return = ret
 
-	tok, _ = p.scanIgnoreWhitespace()
+			tok, lit = p.scanIgnoreWhitespace()
+			if tok == LIMIT {
+				tok, lit = p.scanIgnoreWhitespace()
+				limit, err := strconv.Atoi(lit)
+				if tok != STRING || !isDigits(lit) || err != nil {
+					return nil, fmt.Errorf("not able to find a correct LIMIT (positive integer expected: %s)", lit)
+				}
+				cypher.Limit = &limit
+			} else {
+				p.unscan(TokenInfo{Token: tok, Literal: lit})
+			}
+			return &cypher, nil
+		case EOF:
+			p.unscan(TokenInfo{Token: tok, Literal: lit})
+			return &cypher, nil
+		default:
+			return nil, fmt.Errorf("unexpected '%s', expected MATCH, OPTIONAL MATCH, WITH or RETURN", lit)
+		}
+	}
+}
+
+// parseWith scans stuff like "a, count(m) AS movies WHERE movies > 3" (the WITH keyword is already scanned)
+func (p *Parser) parseWith() (*CypherWith, error) {
+	projections, err := p.parseReturn()
+	if err != nil {
+		return nil, err
+	}
+	if len(projections) == 0 {
+		return nil, fmt.Errorf("missing projection after WITH")
+	}
+	with := CypherWith{Projections: projections}
+
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != WHERE {
+		p.unscan(TokenInfo{Token: tok, Literal: lit})
+		return &with, nil
+	}
+
+	where, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	with.Where = where
+	return &with, nil
+}
+
+// parseMatch scans the pattern and the optional WHERE clause of a MATCH clause (the MATCH keyword is already scanned)
+func (p *Parser) parseMatch(optional bool) (*CypherMatch, error) {
+	node, rel, err := p.parsePattern()
+	if err != nil {
+		return nil, err
+	}
+	match := CypherMatch{
+		Optional:     optional,
+		Node:         *node,
+		Relationship: rel,
+	}
+
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != WHERE {
+		p.unscan(TokenInfo{Token: tok, Literal: lit})
+		return &match, nil
+	}
+
+	where, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	match.Where = where
+	return &match, nil
+}
+
+// parsePattern scans stuff like "(n:Person)-[r]->(o:Person)"
+func (p *Parser) parsePattern() (*CypherNode, *CypherRelationShip, error) {
+	node, err := p.parseNode()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tok, lit := p.scanIgnoreWhitespace()
 
 	// -->
 	if tok == RELATIONSHIP {
 		rel := CypherRelationShip{}
-		cypher.Relationship = &rel
 
-		var lit string
 		tok, lit = p.scanIgnoreWhitespace()
+		if tok == TO_RELATIONSHIP {
+			rel.Direction = REL_TO
+		}
 		// relationship props to scan
 		if tok != TO_RELATIONSHIP && tok != RELATIONSHIP {
 			p.unscan(TokenInfo{Token: tok, Literal: lit})
 
-			relProps, err := p.parseRelationshipProperties()
+			relProps, hops, err := p.parseRelationshipProperties()
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			rel.Props = relProps
+			rel.Hops = hops
 
 			tok, lit = p.scanIgnoreWhitespace()
 			if tok != TO_RELATIONSHIP && tok != RELATIONSHIP {
-				return nil, fmt.Errorf("expected '->' or '-'. Got %s (%d)", lit, tok)
+				return nil, nil, fmt.Errorf("expected '->' or '-'. Got %s (%d)", lit, tok)
 			}
 			if tok == TO_RELATIONSHIP {
 				rel.Direction = REL_TO
@@ -72,13 +227,13 @@ func (p *Parser) parseQuery() (*CypherQuery, error) {
 		}
 
 		// and the target node
-		node, err := p.parseNode()
+		target, err := p.parseNode()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		rel.Target = *node
+		rel.Target = *target
 
-		tok, _ = p.scanIgnoreWhitespace()
+		return node, &rel, nil
 	}
 
 	// <--
@@ -86,85 +241,403 @@ func (p *Parser) parseQuery() (*CypherQuery, error) {
 		rel := CypherRelationShip{
 			Direction: REL_FROM,
 		}
-		cypher.Relationship = &rel
 
-		var lit string
 		tok, lit = p.scanIgnoreWhitespace()
 		if tok != RELATIONSHIP {
 			p.unscan(TokenInfo{Token: tok, Literal: lit})
 
-			relProps, err := p.parseRelationshipProperties()
+			relProps, hops, err := p.parseRelationshipProperties()
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			rel.Props = relProps
+			rel.Hops = hops
 
 			tok, _ = p.scanIgnoreWhitespace()
 			if tok != RELATIONSHIP {
-				return nil, fmt.Errorf("expected '-'")
+				return nil, nil, fmt.Errorf("expected '-'")
 			}
 		}
 
 		// and the target node
-		node, err := p.parseNode()
+		target, err := p.parseNode()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		rel.Target = *node
+		rel.Target = *target
 
-		tok, _ = p.scanIgnoreWhitespace()
-	}
-
-	if tok == RETURN {
-		ret, err := p.parseReturn()
-		if err != nil {
-			return nil, err
-		}
-		cypher.Return = ret
+		return node, &rel, nil
 	}
 
-	return &cypher, nil
+	p.unscan(TokenInfo{Token: tok, Literal: lit})
+	return node, nil, nil
 }
 
-// parseReturn scans stuff like "a,b.propname"
+// parseReturn scans stuff like "a,b.propname,count(DISTINCT c) AS cs"
 func (p *Parser) parseReturn() (CypherReturn, error) {
 	ret := CypherReturn{}
 	tok, lit := p.scanIgnoreWhitespace()
+	if tok == EOF {
+		return ret, nil
+	}
 
-	for tok != EOF {
+	for {
 		if tok != STRING {
 			return nil, fmt.Errorf("not able to find a correct return definition (return element name missing: %s)", lit)
 		}
-		elementName := lit
-		retElement := CypherVariableReturn{
-			VariableName: elementName,
+		retElement, err := p.parseReturnElement(lit)
+		if err != nil {
+			return nil, err
 		}
+		ret = append(ret, *retElement)
 
-		tok, _ = p.scanIgnoreWhitespace()
+		tok, lit = p.scanIgnoreWhitespace()
+		if tok != COMMA {
+			// end of the projections
+			p.unscan(TokenInfo{Token: tok, Literal: lit})
+			return ret, nil
+		}
 
-		if tok == DOT {
-			tok, lit = p.scanIgnoreWhitespace()
-			if tok != STRING {
-				return nil, fmt.Errorf("not able to find a correct return definition (return element property missing: %s)", lit)
-			}
-			elementProperty := lit
-			retElement.Property = &elementProperty
-			tok, _ = p.scanIgnoreWhitespace()
+		tok, lit = p.scanIgnoreWhitespace()
+		if tok == EOF {
+			return nil, fmt.Errorf("missing return value after comma")
 		}
+	}
+}
+
+// parseReturnElement scans stuff like "b.propname" or "count(DISTINCT c) AS cs" (the first name is already scanned)
+func (p *Parser) parseReturnElement(name string) (*CypherVariableReturn, error) {
+	retElement := CypherVariableReturn{
+		VariableName: name,
+		Pos:          p.pos,
+	}
 
-		if tok != EOF && tok != COMMA {
-			return nil, fmt.Errorf("not able to find a correct return definition (comma expected)")
+	tok, lit := p.scanIgnoreWhitespace()
+
+	// function, i.e. "count(c)"
+	isFunction := tok == OPEN_PARENTHESIS
+	if isFunction {
+		function := strings.ToLower(name)
+		if !supportedFunctions[function] {
+			return nil, fmt.Errorf("function %s is not supported", name)
 		}
-		ret = append(ret, retElement)
+		retElement.Function = &function
 
-		if tok == COMMA {
+		tok, lit = p.scanIgnoreWhitespace()
+		if tok == DISTINCT {
+			retElement.Distinct = true
 			tok, lit = p.scanIgnoreWhitespace()
-			if tok == EOF {
-				return nil, fmt.Errorf("missing return value after comma)")
+		}
+		if tok != STRING && tok != ASTERISK {
+			return nil, fmt.Errorf("not able to find a correct return definition (function argument missing: %s)", lit)
+		}
+		if tok == STRING && !isIdentifier(lit) {
+			return nil, fmt.Errorf("not able to find a correct return definition (invalid function argument: %s)", lit)
+		}
+		retElement.VariableName = lit
+		tok, lit = p.scanIgnoreWhitespace()
+	} else if !isIdentifier(name) {
+		return nil, fmt.Errorf("not able to find a correct return definition (invalid return element name: %s)", name)
+	}
+
+	if tok == DOT {
+		tok, lit = p.scanIgnoreWhitespace()
+		if tok != STRING || !isIdentifier(lit) {
+			return nil, fmt.Errorf("not able to find a correct return definition (return element property missing: %s)", lit)
+		}
+		elementProperty := lit
+		retElement.Property = &elementProperty
+		tok, lit = p.scanIgnoreWhitespace()
+	}
+
+	if isFunction {
+		if tok != CLOSED_PARENTHESIS {
+			return nil, fmt.Errorf("not able to find a correct return definition (')' expected after the function argument)")
+		}
+		tok, lit = p.scanIgnoreWhitespace()
+	}
+
+	// alias, i.e. "AS cs"
+	if tok == AS {
+		tok, lit = p.scanIgnoreWhitespace()
+		if tok != STRING || !isIdentifier(lit) {
+			return nil, fmt.Errorf("not able to find a correct return definition (alias missing after AS: %s)", lit)
+		}
+		alias := lit
+		retElement.Alias = &alias
+		tok, lit = p.scanIgnoreWhitespace()
+	}
+
+	p.unscan(TokenInfo{Token: tok, Literal: lit})
+	return &retElement, nil
+}
+
+// parseExpression scans stuff like "a.name = 'Tom' AND NOT (movies < 3 OR movies > 10)"
+func (p *Parser) parseExpression() (CypherExpression, error) {
+	return p.parseOrExpression()
+}
+
+// parseOrExpression scans stuff like "a OR b"
+func (p *Parser) parseOrExpression() (CypherExpression, error) {
+	left, err := p.parseAndExpression()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.scanTokenIgnoreWhitespace()
+		if tok.Token != OR {
+			p.unscan(tok)
+			return left, nil
+		}
+		right, err := p.parseAndExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &CypherBinaryExpression{Operator: "OR", Left: left, Right: right}
+	}
+}
+
+// parseAndExpression scans stuff like "a AND b"
+func (p *Parser) parseAndExpression() (CypherExpression, error) {
+	left, err := p.parseNotExpression()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.scanTokenIgnoreWhitespace()
+		if tok.Token != AND {
+			p.unscan(tok)
+			return left, nil
+		}
+		right, err := p.parseNotExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &CypherBinaryExpression{Operator: "AND", Left: left, Right: right}
+	}
+}
+
+// parseNotExpression scans stuff like "NOT a"
+func (p *Parser) parseNotExpression() (CypherExpression, error) {
+	tok := p.scanTokenIgnoreWhitespace()
+	if tok.Token != NOT {
+		p.unscan(tok)
+		return p.parseComparisonExpression()
+	}
+	expr, err := p.parseNotExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &CypherNotExpression{Expression: expr}, nil
+}
+
+// parseComparisonExpression scans stuff like "a.born >= 1970", "a.name STARTS WITH 'To'" or "a.born IS NOT NULL"
+func (p *Parser) parseComparisonExpression() (CypherExpression, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.scanTokenIgnoreWhitespace()
+	operator := tok.Literal
+	switch {
+	case comparisonOperators[tok.Token]:
+	case tok.Token == STARTS || tok.Token == ENDS:
+		if next := p.scanTokenIgnoreWhitespace(); next.Token != WITH {
+			return nil, fmt.Errorf("expected WITH after %s. Got %s", tok.Literal, next.Literal)
+		}
+		operator += " WITH"
+	case tok.Token == IS:
+		isNull := CypherIsNullExpression{Expression: left}
+		next := p.scanTokenIgnoreWhitespace()
+		if next.Token == NOT {
+			isNull.Not = true
+			next = p.scanTokenIgnoreWhitespace()
+		}
+		if next.Token != NULL {
+			return nil, fmt.Errorf("expected NULL after IS. Got %s", next.Literal)
+		}
+		return &isNull, nil
+	default:
+		p.unscan(tok)
+		return left, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &CypherBinaryExpression{Operator: operator, Left: left, Right: right}, nil
+}
+
+// parseOperand scans stuff like "a", "a.name", "'Tom'", "-3.5", "[1999,2003]", "(a OR b)", "(a)-->()" or "EXISTS { ... }"
+func (p *Parser) parseOperand() (CypherExpression, error) {
+	tok := p.scanTokenIgnoreWhitespace()
+
+	switch {
+	case tok.Token == OPEN_PARENTHESIS && p.isPatternAhead():
+		p.unscan(tok)
+		return p.parsePatternExpression(tok.Pos)
+	case tok.Token == EXISTS:
+		return p.parseExists()
+	case tok.Token == OPEN_BRACKET:
+		return p.parseList()
+	case tok.Token == NULL:
+		return &CypherLiteralExpression{Value: "null"}, nil
+	case tok.Token == OPEN_PARENTHESIS:
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.scanTokenIgnoreWhitespace(); next.Token != CLOSED_PARENTHESIS {
+			return nil, fmt.Errorf("expected ')'. Got %s", next.Literal)
+		}
+		return &CypherParenthesisExpression{Expression: expr}, nil
+	case tok.Token == RELATIONSHIP:
+		// negative number
+		next := p.scanToken()
+		if next.Token != STRING || next.Quoted || !isDigits(next.Literal) {
+			return nil, fmt.Errorf("expected a number after '-'. Got %s", next.Literal)
+		}
+		return p.parseNumber("-" + next.Literal)
+	case tok.Token == STRING && tok.Quoted:
+		return &CypherLiteralExpression{Value: tok.Literal, Quoted: true}, nil
+	case tok.Token == STRING && isDigits(tok.Literal):
+		return p.parseNumber(tok.Literal)
+	case tok.Token == STRING && isConstant(tok.Literal):
+		return &CypherLiteralExpression{Value: strings.ToLower(tok.Literal)}, nil
+	case tok.Token == STRING && isIdentifier(tok.Literal):
+		expr := CypherPropertyExpression{VariableName: tok.Literal, Pos: tok.Pos}
+		next := p.scanToken()
+		if next.Token != DOT {
+			p.unscan(next)
+			return &expr, nil
+		}
+		next = p.scanToken()
+		if next.Token != STRING || !isIdentifier(next.Literal) {
+			return nil, fmt.Errorf("expected a property name after '%s.'. Got %s", tok.Literal, next.Literal)
+		}
+		property := next.Literal
+		expr.Property = &property
+		return &expr, nil
+	}
+	return nil, fmt.Errorf("expected an expression. Got '%s'", tok.Literal)
+}
+
+// isPatternAhead returns true if the tokens following a '(' start a pattern, i.e. "(p)-[:DIRECTED]->()", and not an
+// expression surrounded by parenthesis, i.e. "(p.born > 1970)". The tokens it scans are unscanned.
+func (p *Parser) isPatternAhead() bool {
+	scanned := []TokenInfo{}
+	next := func() TokenInfo {
+		tok := p.scanTokenIgnoreWhitespace()
+		scanned = append(scanned, tok)
+		return tok
+	}
+	defer func() {
+		for i := len(scanned) - 1; i >= 0; i-- {
+			p.unscan(scanned[i])
+		}
+	}()
+
+	tok := next()
+	switch tok.Token {
+	case CLOSED_PARENTHESIS, DOUBLECOLON:
+		// "()" or "(:Person)"
+		return true
+	case STRING:
+		switch next().Token {
+		case DOUBLECOLON, OPEN_CURLYBRACKET:
+			// "(p:Person)" or "(p{name:'Tom'})"
+			return true
+		case CLOSED_PARENTHESIS:
+			// "(p)-->()", and not "(p)"
+			tok = next()
+			return tok.Token == RELATIONSHIP || tok.Token == FROM_RELATIONSHIP
+		}
+	}
+	return false
+}
+
+// parsePatternExpression scans stuff like "(p)-[:DIRECTED]->()", starting at pos
+func (p *Parser) parsePatternExpression(pos int) (CypherExpression, error) {
+	node, rel, err := p.parsePattern()
+	if err != nil {
+		return nil, err
+	}
+	if rel == nil {
+		return nil, fmt.Errorf("a pattern predicate must have a relationship")
+	}
+	return &CypherPatternExpression{Node: *node, Relationship: rel, Pos: pos}, nil
+}
+
+// parseExists scans stuff like "{ MATCH (p)-[:DIRECTED]->(m) WHERE m.released > 2000 }" (the EXISTS keyword is already scanned)
+func (p *Parser) parseExists() (CypherExpression, error) {
+	exists := CypherExistsExpression{}
+
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != OPEN_CURLYBRACKET {
+		return nil, fmt.Errorf("expected '{' after EXISTS. Got %s", lit)
+	}
+
+	for {
+		tok, lit = p.scanIgnoreWhitespace()
+		switch tok {
+		case MATCH:
+			match, err := p.parseMatch(false)
+			if err != nil {
+				return nil, err
+			}
+			exists.Matches = append(exists.Matches, *match)
+		case CLOSED_CURLYBRACKET:
+			if len(exists.Matches) == 0 {
+				return nil, fmt.Errorf("missing MATCH in EXISTS subquery")
 			}
+			return &exists, nil
+		default:
+			return nil, fmt.Errorf("unexpected '%s' in EXISTS subquery, expected MATCH or '}'", lit)
 		}
 	}
-	return ret, nil
+}
+
+// parseList scans stuff like "1999, 2003]" (the '[' is already scanned)
+func (p *Parser) parseList() (CypherExpression, error) {
+	list := CypherListExpression{Items: []CypherExpression{}}
+
+	tok := p.scanTokenIgnoreWhitespace()
+	if tok.Token == CLOSED_BRACKET {
+		return &list, nil
+	}
+	p.unscan(tok)
+
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, item)
+
+		tok = p.scanTokenIgnoreWhitespace()
+		if tok.Token == CLOSED_BRACKET {
+			return &list, nil
+		}
+		if tok.Token != COMMA {
+			return nil, fmt.Errorf("not able to find a correct list (comma or bracket missing: %s)", tok.Literal)
+		}
+	}
+}
+
+// parseNumber scans the (optional) decimal part of a number, i.e. ".5" in "3.5" (the integer part is already scanned)
+func (p *Parser) parseNumber(integer string) (CypherExpression, error) {
+	tok := p.scanToken()
+	if tok.Token != DOT {
+		p.unscan(tok)
+		return &CypherLiteralExpression{Value: integer}, nil
+	}
+	decimal := p.scanToken()
+	if decimal.Token != STRING || decimal.Quoted || !isDigits(decimal.Literal) {
+		return nil, fmt.Errorf("not able to find a correct number (%s.%s)", integer, decimal.Literal)
+	}
+	return &CypherLiteralExpression{Value: integer + "." + decimal.Literal}, nil
 }
 
 // parseNode scans stuff like "(a:Person{foo:'bar'})"
@@ -184,6 +657,9 @@ func (p *Parser) parseNode() (*CypherNode, error) {
 	}
 
 	if tok == STRING {
+		if !isIdentifier(lit) {
+			return nil, fmt.Errorf("invalid variable name: %s", lit)
+		}
 		variableName := lit
 		node.VariableName = &variableName
 
@@ -199,6 +675,9 @@ func (p *Parser) parseNode() (*CypherNode, error) {
 		if tok != STRING {
 			return nil, fmt.Errorf("missing type definition after ':'")
 		}
+		if !isIdentifier(lit) {
+			return nil, fmt.Errorf("invalid type name: %s", lit)
+		}
 		typeName := lit
 		node.TypeName = &typeName
 
@@ -227,64 +706,127 @@ func (p *Parser) parseNode() (*CypherNode, error) {
 	return &node, nil
 }
 
-// parseNode scans stuff like "(a:Person{foo:'bar'})"
-func (p *Parser) parseRelationshipProperties() (*CypherNode, error) {
+// parseRelationshipProperties scans stuff like "[r:KNOWS*1..3{foo:'bar'}]"
+func (p *Parser) parseRelationshipProperties() (*CypherNode, *CypherHops, error) {
 
 	node := CypherNode{}
+	var hops *CypherHops
 
 	tok, _ := p.scanIgnoreWhitespace()
 	if tok != OPEN_BRACKET {
-		return nil, fmt.Errorf("not able to find a correct relationship definition")
+		return nil, nil, fmt.Errorf("not able to find a correct relationship definition")
 	}
 	tok, lit := p.scanIgnoreWhitespace()
 
 	if tok == CLOSED_BRACKET {
-		// empty "()"" node definition
-		return &node, nil
+		// empty "[]" relationship definition
+		return &node, nil, nil
 	}
 
 	if tok == STRING {
+		if !isIdentifier(lit) {
+			return nil, nil, fmt.Errorf("invalid variable name: %s", lit)
+		}
 		variableName := lit
 		node.VariableName = &variableName
 
 		tok, lit = p.scanIgnoreWhitespace()
 		if tok == CLOSED_BRACKET {
-			// end, i.e. "(n)"
-			return &node, nil
+			// end, i.e. "[r]"
+			return &node, nil, nil
 		}
 	}
 
 	if tok == DOUBLECOLON {
 		tok, lit = p.scanIgnoreWhitespace()
 		if tok != STRING {
-			return nil, fmt.Errorf("missing type definition after ':'")
+			return nil, nil, fmt.Errorf("missing type definition after ':'")
+		}
+		if !isIdentifier(lit) {
+			return nil, nil, fmt.Errorf("invalid type name: %s", lit)
 		}
 		typeName := lit
 		node.TypeName = &typeName
 
 		tok, lit = p.scanIgnoreWhitespace()
 		if tok == CLOSED_BRACKET {
-			// end, i.e. "(n:t)"
-			return &node, nil
+			// end, i.e. "[r:t]"
+			return &node, nil, nil
+		}
+	}
+
+	if tok == ASTERISK {
+		var err error
+		hops, err = p.parseHops()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tok, lit = p.scanIgnoreWhitespace()
+		if tok == CLOSED_BRACKET {
+			// end, i.e. "[r:t*1..3]"
+			return &node, hops, nil
 		}
 	}
 
 	if tok != OPEN_CURLYBRACKET {
-		return nil, fmt.Errorf("missing '{ ... }'")
+		return nil, nil, fmt.Errorf("missing '{ ... }'")
 	}
 
 	p.unscan(TokenInfo{Token: tok, Literal: lit})
 	props, err := p.parseProperties()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	node.Props = props
 
 	tok, _ = p.scanIgnoreWhitespace()
 	if tok != CLOSED_BRACKET {
-		return nil, fmt.Errorf("not able to find a correct node definition")
+		return nil, nil, fmt.Errorf("not able to find a correct relationship definition")
 	}
-	return &node, nil
+	return &node, hops, nil
+}
+
+// parseHops scans stuff like "", "2", "1..3", "..3" or "2.." (the '*' is already scanned)
+func (p *Parser) parseHops() (*CypherHops, error) {
+	hops := CypherHops{}
+
+	tok := p.scanTokenIgnoreWhitespace()
+	if tok.Token == STRING && !tok.Quoted && isDigits(tok.Literal) {
+		min, err := strconv.Atoi(tok.Literal)
+		if err != nil {
+			return nil, fmt.Errorf("not able to find a correct number of hops (%s)", tok.Literal)
+		}
+		hops.Min = &min
+		tok = p.scanTokenIgnoreWhitespace()
+	}
+
+	if tok.Token != DOT {
+		// "*" or "*2"
+		p.unscan(tok)
+		hops.Max = hops.Min
+		return &hops, nil
+	}
+	tok = p.scanToken()
+	if tok.Token != DOT {
+		return nil, fmt.Errorf("expected '..' in the number of hops. Got %s", tok.Literal)
+	}
+
+	tok = p.scanTokenIgnoreWhitespace()
+	if tok.Token == STRING && !tok.Quoted && isDigits(tok.Literal) {
+		max, err := strconv.Atoi(tok.Literal)
+		if err != nil {
+			return nil, fmt.Errorf("not able to find a correct number of hops (%s)", tok.Literal)
+		}
+		hops.Max = &max
+	} else {
+		p.unscan(tok)
+	}
+
+	if hops.Min != nil && hops.Max != nil && *hops.Min > *hops.Max {
+		return nil, fmt.Errorf("the minimum number of hops (%d) is greater than the maximum (%d)", *hops.Min, *hops.Max)
+	}
+	return &hops, nil
 }
 
 // parseProperties scans stuff like "{foo:'bar'}"
@@ -301,6 +843,9 @@ func (p *Parser) parseProperties() (map[string]string, error) {
 		if tok != STRING {
 			return nil, fmt.Errorf("not able to find a correct properties definition (property name missng)")
 		}
+		if !isIdentifier(lit) {
+			return nil, fmt.Errorf("not able to find a correct properties definition (invalid property name: %s)", lit)
+		}
 		propName := lit
 
 		tok, _ = p.scanIgnoreWhitespace()
@@ -324,32 +869,115 @@ func (p *Parser) parseProperties() (map[string]string, error) {
 
 }
 
-// scan returns the next token from the underlying scanner.
+// scanToken returns the next token (with its information) from the underlying scanner.
 // If a token has been unscanned then read that instead.
-func (p *Parser) scan() (tok Token, lit string) {
+func (p *Parser) scanToken() TokenInfo {
 	// If we have a token on the buffer, then return it.
 	if p.buf.Len() != 0 {
 		// Can ignore the error since it's not empty.
 		tokenInf, _ := p.buf.Pop()
-		return tokenInf.Token, tokenInf.Literal
+		p.pos = tokenInf.Pos
+		return tokenInf
 	}
 
 	// Otherwise read the next token from the scanner.
 	tokenInf := p.s.Scan()
-	tok, lit = tokenInf.Token, tokenInf.Literal
-	return tok, lit
+	p.pos = tokenInf.Pos
+	return tokenInf
+}
+
+// scan returns the next token from the underlying scanner.
+// If a token has been unscanned then read that instead.
+func (p *Parser) scan() (tok Token, lit string) {
+	tokenInf := p.scanToken()
+	return tokenInf.Token, tokenInf.Literal
+}
+
+// scanTokenIgnoreWhitespace scans the next non-whitespace token (with its information).
+func (p *Parser) scanTokenIgnoreWhitespace() TokenInfo {
+	tokenInf := p.scanToken()
+	if tokenInf.Token == WS {
+		tokenInf = p.scanToken()
+	}
+	return tokenInf
 }
 
 // scanIgnoreWhitespace scans the next non-whitespace token.
 func (p *Parser) scanIgnoreWhitespace() (tok Token, lit string) {
-	tok, lit = p.scan()
-	if tok == WS {
-		tok, lit = p.scan()
-	}
-	return tok, lit
+	tokenInf := p.scanTokenIgnoreWhitespace()
+	return tokenInf.Token, tokenInf.Literal
 }
 
 // unscan pushes the previously read tokens back onto the buffer.
+// A token without position (rebuilt from its token and literal) gets the position of the last scanned token.
 func (p *Parser) unscan(tok TokenInfo) {
+	if tok.Pos == 0 {
+		tok.Pos = p.pos
+	}
 	p.buf.Push(tok)
 }
+
+// supportedFunctions are the functions allowed in a WITH or RETURN clause
+var supportedFunctions = map[string]bool{
+	// aggregating functions
+	"count":   true,
+	"collect": true,
+	"sum":     true,
+	"avg":     true,
+	"min":     true,
+	"max":     true,
+	// scalar functions
+	"id":     true,
+	"labels": true,
+	"type":   true,
+	"keys":   true,
+}
+
+// comparisonOperators are the (single token) operators allowed between two operands of an expression
+var comparisonOperators = map[Token]bool{
+	EQUAL:            true,
+	NOT_EQUAL:        true,
+	LESS_THAN:        true,
+	GREATER_THAN:     true,
+	LESS_OR_EQUAL:    true,
+	GREATER_OR_EQUAL: true,
+	REGEX_MATCH:      true,
+	IN:               true,
+	CONTAINS:         true,
+}
+
+// isIdentifier returns true if s can be used as a variable, property or alias name
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, ch := range s {
+		if ch == '_' || unicode.IsLetter(ch) || (i > 0 && unicode.IsDigit(ch)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// isDigits returns true if s is only made of digits, i.e. the integer (or decimal) part of a number
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isConstant returns true for the true and false literals
+func isConstant(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "false":
+		return true
+	}
+	return false
+}
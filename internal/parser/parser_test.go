@@ -60,21 +60,21 @@ func TestParser(t *testing.T) {
 	t.Run("test parse relationship definition 1", func(t *testing.T) {
 		s := "[]"
 		parser := NewParser(s)
-		r, err := parser.parseRelationshipProperties()
+		r, _, err := parser.parseRelationshipProperties()
 		assert.Nil(t, err)
 		assert.Nil(t, r.VariableName)
 	})
 	t.Run("test parse relationship definition 2", func(t *testing.T) {
 		s := "[n]"
 		parser := NewParser(s)
-		r, err := parser.parseRelationshipProperties()
+		r, _, err := parser.parseRelationshipProperties()
 		assert.Nil(t, err)
 		assert.Equal(t, "n", *r.VariableName)
 	})
 	t.Run("test parse relationship definition 3", func(t *testing.T) {
 		s := "[n:Person]"
 		parser := NewParser(s)
-		r, err := parser.parseRelationshipProperties()
+		r, _, err := parser.parseRelationshipProperties()
 		assert.Nil(t, err)
 		assert.Equal(t, "n", *r.VariableName)
 		assert.Equal(t, "Person", *r.TypeName)
@@ -82,7 +82,7 @@ func TestParser(t *testing.T) {
 	t.Run("test parse relationship definition 4", func(t *testing.T) {
 		s := "[n:Person{foo:'bar'}]"
 		parser := NewParser(s)
-		r, err := parser.parseRelationshipProperties()
+		r, _, err := parser.parseRelationshipProperties()
 		assert.Nil(t, err)
 		assert.Equal(t, "n", *r.VariableName)
 		assert.Equal(t, "Person", *r.TypeName)
@@ -91,12 +91,41 @@ func TestParser(t *testing.T) {
 	t.Run("test parse relationship definition 5", func(t *testing.T) {
 		s := "[:Person{foo:'bar'}]"
 		parser := NewParser(s)
-		r, err := parser.parseRelationshipProperties()
+		r, _, err := parser.parseRelationshipProperties()
 		assert.Nil(t, err)
 		assert.Nil(t, r.VariableName)
 		assert.Equal(t, "Person", *r.TypeName)
 		assert.Equal(t, "bar", r.Props["foo"])
 	})
+	t.Run("test parse variable-length relationship", func(t *testing.T) {
+		for s, expected := range map[string]string{
+			"[*]":                        "*",
+			"[r*2]":                      "*2",
+			"[:KNOWS*1..3]":              "*1..3",
+			"[r:KNOWS*..3]":              "*..3",
+			"[r:KNOWS* 2.. {foo:'bar'}]": "*2..",
+		} {
+			parser := NewParser(s)
+			_, hops, err := parser.parseRelationshipProperties()
+			assert.Nil(t, err, s)
+			assert.Equal(t, expected, hops.ToString(), s)
+		}
+
+		parser := NewParser("[r:KNOWS*1..3]")
+		_, hops, _ := parser.parseRelationshipProperties()
+		assert.Equal(t, 1, *hops.Min)
+		assert.Equal(t, 3, *hops.Max)
+
+		parser = NewParser("[*]")
+		_, hops, _ = parser.parseRelationshipProperties()
+		assert.Nil(t, hops.Max)
+
+		for _, s := range []string{"[*3..1]", "[*1.3]", "[*'1'..3]"} {
+			parser := NewParser(s)
+			_, _, err := parser.parseRelationshipProperties()
+			assert.NotNil(t, err, s)
+		}
+	})
 
 	t.Run("test return 1", func(t *testing.T) {
 		s := "a"
@@ -118,6 +147,116 @@ func TestParser(t *testing.T) {
 		assert.Equal(t, "b", ret[1].VariableName)
 	})
 
+	t.Run("test return 3", func(t *testing.T) {
+		s := "a, count(DISTINCT m) AS movies, collect(m.title) AS titles, count(*)"
+		parser := NewParser(s)
+		ret, err := parser.parseReturn()
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(ret))
+		assert.Equal(t, "count", *ret[1].Function)
+		assert.True(t, ret[1].Distinct)
+		assert.Equal(t, "m", ret[1].VariableName)
+		assert.Equal(t, "movies", ret[1].Name())
+		assert.Equal(t, "title", *ret[2].Property)
+		assert.Equal(t, "count(*)", ret[3].Name())
+		assert.Equal(t, "a,count(DISTINCT m) AS movies,collect(m.title) AS titles,count(*)", ret.ToString())
+	})
+	t.Run("not happy test return 1", func(t *testing.T) {
+		s := "apoc(a)"
+		parser := NewParser(s)
+		_, err := parser.parseReturn()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy test return 2", func(t *testing.T) {
+		s := "count(a AS b"
+		parser := NewParser(s)
+		_, err := parser.parseReturn()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("test expression 1", func(t *testing.T) {
+		s := "movies > 3"
+		parser := NewParser(s)
+		expr, err := parser.parseExpression()
+		assert.Nil(t, err)
+		binary := expr.(*CypherBinaryExpression)
+		assert.Equal(t, ">", binary.Operator)
+		assert.Equal(t, "movies", binary.Left.(*CypherPropertyExpression).VariableName)
+		assert.Equal(t, "3", binary.Right.(*CypherLiteralExpression).Value)
+	})
+	t.Run("test expression 2", func(t *testing.T) {
+		s := "a.name = 'Tom' OR NOT (b.born<=-1.5 AND c <> true)"
+		parser := NewParser(s)
+		expr, err := parser.parseExpression()
+		assert.Nil(t, err)
+		or := expr.(*CypherBinaryExpression)
+		assert.Equal(t, "OR", or.Operator)
+		not := or.Right.(*CypherNotExpression)
+		and := not.Expression.(*CypherParenthesisExpression).Expression.(*CypherBinaryExpression)
+		assert.Equal(t, "AND", and.Operator)
+		assert.Equal(t, "-1.5", and.Left.(*CypherBinaryExpression).Right.(*CypherLiteralExpression).Value)
+		assert.Equal(t, "a.name = 'Tom' OR NOT (b.born <= -1.5 AND c <> true)", expr.toString(nil))
+	})
+	t.Run("test expression precedence", func(t *testing.T) {
+		s := "a = 1 OR b = 2 AND c = 3"
+		parser := NewParser(s)
+		expr, err := parser.parseExpression()
+		assert.Nil(t, err)
+		or := expr.(*CypherBinaryExpression)
+		assert.Equal(t, "OR", or.Operator)
+		assert.Equal(t, "AND", or.Right.(*CypherBinaryExpression).Operator)
+	})
+	t.Run("test predicate expression 1", func(t *testing.T) {
+		s := "m.released IN [1999, 2003] AND m.title STARTS WITH 'The' AND m.title ENDS WITH 'x' AND m.title CONTAINS 'Matrix'"
+		parser := NewParser(s)
+		expr, err := parser.parseExpression()
+		assert.Nil(t, err)
+		operators := []string{}
+		WalkExpression(expr, func(e CypherExpression) bool {
+			if binary, ok := e.(*CypherBinaryExpression); ok && binary.Operator != "AND" {
+				operators = append(operators, binary.Operator)
+			}
+			return true
+		})
+		assert.Equal(t, []string{"IN", "STARTS WITH", "ENDS WITH", "CONTAINS"}, operators)
+		assert.Equal(t, "m.released IN [1999,2003] AND m.title STARTS WITH 'The' AND m.title ENDS WITH 'x' AND m.title CONTAINS 'Matrix'", expr.toString(nil))
+	})
+	t.Run("test predicate expression 2", func(t *testing.T) {
+		s := "a.born IS NOT NULL OR a.died IS null OR a.name =~ 'Tom.*'"
+		parser := NewParser(s)
+		expr, err := parser.parseExpression()
+		assert.Nil(t, err)
+		or := expr.(*CypherBinaryExpression).Left.(*CypherBinaryExpression)
+		assert.True(t, or.Left.(*CypherIsNullExpression).Not)
+		assert.False(t, or.Right.(*CypherIsNullExpression).Not)
+		assert.Equal(t, "=~", expr.(*CypherBinaryExpression).Right.(*CypherBinaryExpression).Operator)
+		assert.Equal(t, "a.born IS NOT NULL OR a.died IS NULL OR a.name =~ 'Tom.*'", expr.toString(nil))
+	})
+	t.Run("not happy test predicate expression 1", func(t *testing.T) {
+		s := "a.name STARTS 'To'"
+		parser := NewParser(s)
+		_, err := parser.parseExpression()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy test predicate expression 2", func(t *testing.T) {
+		s := "a.name IN [1, 2"
+		parser := NewParser(s)
+		_, err := parser.parseExpression()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy test predicate expression 3", func(t *testing.T) {
+		s := "a.name IS NOT 'Tom'"
+		parser := NewParser(s)
+		_, err := parser.parseExpression()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy test expression", func(t *testing.T) {
+		s := "a = (b"
+		parser := NewParser(s)
+		_, err := parser.parseExpression()
+		assert.NotNil(t, err)
+	})
+
 	t.Run("complete test 1", func(t *testing.T) {
 		s := "MATCH (n) RETURN n"
 		parser := NewParser(s)
@@ -168,6 +307,209 @@ func TestParser(t *testing.T) {
 		assert.Equal(t, REL_FROM, node.Relationship.Direction)
 	})
 
+	t.Run("optional match test 1", func(t *testing.T) {
+		s := "MATCH (m:Movie) OPTIONAL MATCH (m)<-[:DIRECTED]-(d:Person) RETURN m.title,d.name"
+		parser := NewParser(s)
+		node, err := parser.parseQuery()
+		assert.Nil(t, err)
+		assert.Equal(t, "m", *node.MatchNode.VariableName)
+		assert.Nil(t, node.Relationship)
+		assert.Equal(t, 1, len(node.Matches))
+		assert.True(t, node.Matches[0].Optional)
+		assert.Equal(t, "m", *node.Matches[0].Node.VariableName)
+		assert.Equal(t, REL_FROM, node.Matches[0].Relationship.Direction)
+		assert.Equal(t, "DIRECTED", *node.Matches[0].Relationship.Props.TypeName)
+		assert.Equal(t, "d", *node.Matches[0].Relationship.Target.VariableName)
+		assert.Equal(t, 2, len(node.Return))
+	})
+	t.Run("optional match test 2", func(t *testing.T) {
+		s := "MATCH (m:Movie) MATCH (m)<--(p) optional match (p)-[:DIRECTED]->(o) RETURN p"
+		parser := NewParser(s)
+		node, err := parser.parseQuery()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(node.Matches))
+		assert.False(t, node.Matches[0].Optional)
+		assert.True(t, node.Matches[1].Optional)
+		assert.Equal(t, REL_TO, node.Matches[1].Relationship.Direction)
+	})
+
+	t.Run("with test 1", func(t *testing.T) {
+		s := "MATCH (a:Person)-[:ACTED_IN]->(m:Movie) WITH a, count(m) AS movies WHERE movies > 3 RETURN a.name, movies"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(node.With))
+		assert.Equal(t, 2, len(node.With[0].Projections))
+		assert.Equal(t, "movies", node.With[0].Projections[1].Name())
+		assert.NotNil(t, node.With[0].Where)
+		assert.Equal(t, 2, len(node.Return))
+	})
+	t.Run("with test 2", func(t *testing.T) {
+		s := "MATCH (a:Person) WITH a MATCH (a)-[:DIRECTED]->(m) WITH a, collect(m.title) AS titles RETURN a.name, titles"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(node.With))
+		assert.Equal(t, 0, len(node.Matches))
+		assert.Equal(t, 1, len(node.With[0].Matches))
+		assert.Nil(t, node.With[0].Where)
+	})
+	t.Run("not happy with test 1", func(t *testing.T) {
+		// m is not in scope anymore after the WITH
+		s := "MATCH (a:Person)-[:ACTED_IN]->(m:Movie) WITH a, count(m) AS movies RETURN m"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy with test 2", func(t *testing.T) {
+		s := "MATCH (a:Person)-[:ACTED_IN]->(m:Movie) WITH a, count(m) AS movies WHERE m.released > 2000 RETURN a"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy with test 3", func(t *testing.T) {
+		// an expression must be aliased in a WITH
+		s := "MATCH (a:Person) WITH a.name RETURN a"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy with test 4", func(t *testing.T) {
+		s := "MATCH (a:Person) WITH RETURN a"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy scope test", func(t *testing.T) {
+		s := "MATCH (a:Person) RETURN b"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("union test 1", func(t *testing.T) {
+		s := "MATCH (a:Person) RETURN a.name AS name UNION MATCH (m:Movie) RETURN m.title AS name LIMIT 5"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.Nil(t, node.Limit)
+		assert.Equal(t, 1, len(node.Unions))
+		assert.False(t, node.Unions[0].All)
+		assert.Equal(t, "m", *node.Unions[0].Query.MatchNode.VariableName)
+		assert.Equal(t, 5, *node.Unions[0].Query.Limit)
+	})
+	t.Run("union test 2", func(t *testing.T) {
+		s := "MATCH (a:Person) RETURN a.name AS name, a UNION ALL MATCH (m:Movie) RETURN m AS a, m.title AS name UNION ALL MATCH (b) RETURN b.name AS name, b AS a"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(node.Unions))
+		assert.True(t, node.Unions[1].All)
+	})
+	t.Run("not happy union test 1", func(t *testing.T) {
+		s := "MATCH (a:Person) RETURN a.name UNION MATCH (m:Movie) RETURN m.title"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy union test 2", func(t *testing.T) {
+		s := "MATCH (a) RETURN a UNION MATCH (a) RETURN a UNION ALL MATCH (a) RETURN a"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy union test 3", func(t *testing.T) {
+		s := "MATCH (a) RETURN a UNION MATCH (a)"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy limit test", func(t *testing.T) {
+		s := "MATCH (a) RETURN a LIMIT -1"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("where test 1", func(t *testing.T) {
+		s := "MATCH (m:Movie) WHERE m.released IN [1999, 2003] OPTIONAL MATCH (m)<-[:DIRECTED]-(d) WHERE d.name =~ 'Lana.*' RETURN m, d"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.NotNil(t, node.Where)
+		assert.NotNil(t, node.Matches[0].Where)
+		assert.True(t, node.UsesRegex())
+	})
+	t.Run("where test 2", func(t *testing.T) {
+		s := "MATCH (m:Movie) WHERE m.title CONTAINS 'Matrix' RETURN m UNION MATCH (m:Person) WHERE m.name =~ 'Tom.*' RETURN m"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.True(t, node.UsesRegex())
+	})
+	t.Run("not happy where test", func(t *testing.T) {
+		s := "MATCH (m:Movie) WHERE d.name = 'Lana' RETURN m"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("pattern predicate test 1", func(t *testing.T) {
+		s := "MATCH (p:Person) WHERE NOT (p)-[:DIRECTED]->() RETURN p.name"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		pattern := node.Where.(*CypherNotExpression).Expression.(*CypherPatternExpression)
+		assert.Equal(t, "p", *pattern.Node.VariableName)
+		assert.Equal(t, "DIRECTED", *pattern.Relationship.Props.TypeName)
+		assert.Equal(t, REL_TO, pattern.Relationship.Direction)
+	})
+	t.Run("pattern predicate test 2", func(t *testing.T) {
+		s := "MATCH (p:Person) WHERE (p.born > 1970) AND (p)<--(:Movie) RETURN p"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		and := node.Where.(*CypherBinaryExpression)
+		assert.IsType(t, &CypherParenthesisExpression{}, and.Left)
+		assert.IsType(t, &CypherPatternExpression{}, and.Right)
+	})
+	t.Run("exists test", func(t *testing.T) {
+		s := "MATCH (p:Person) WHERE EXISTS { MATCH (p)-[:DIRECTED]->(m:Movie) WHERE m.released > 2000 } RETURN p"
+		parser := NewParser(s)
+		node, err := parser.Parse()
+		assert.Nil(t, err)
+		exists := node.Where.(*CypherExistsExpression)
+		assert.Equal(t, 1, len(exists.Matches))
+		assert.Equal(t, "m", *exists.Matches[0].Relationship.Target.VariableName)
+		assert.NotNil(t, exists.Matches[0].Where)
+	})
+	t.Run("not happy pattern predicate test 1", func(t *testing.T) {
+		// a pattern predicate cannot introduce new variables
+		s := "MATCH (p:Person) WHERE (p)-[:DIRECTED]->(m) RETURN p"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy pattern predicate test 2", func(t *testing.T) {
+		s := "MATCH (p:Person) WHERE (p:Director) RETURN p"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy exists test 1", func(t *testing.T) {
+		// m is only in scope inside the subquery
+		s := "MATCH (p:Person) WHERE EXISTS { MATCH (p)-[:DIRECTED]->(m) } RETURN m"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy exists test 2", func(t *testing.T) {
+		s := "MATCH (p:Person) WHERE EXISTS { RETURN p } RETURN p"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		assert.NotNil(t, err)
+	})
+
 	t.Run("not happy complete test 1", func(t *testing.T) {
 		s := "MATCH (n) RETURN n,"
 		parser := NewParser(s)
@@ -192,6 +534,18 @@ func TestParser(t *testing.T) {
 		_, err := parser.parseQuery()
 		assert.NotNil(t, err)
 	})
+	t.Run("not happy optional match test 1", func(t *testing.T) {
+		s := "MATCH (n) OPTIONAL (n)-->(m) RETURN n"
+		parser := NewParser(s)
+		_, err := parser.parseQuery()
+		assert.NotNil(t, err)
+	})
+	t.Run("not happy optional match test 2", func(t *testing.T) {
+		s := "OPTIONAL MATCH (n) RETURN n"
+		parser := NewParser(s)
+		_, err := parser.parseQuery()
+		assert.NotNil(t, err)
+	})
 }
 
 func TestCypherReturn(t *testing.T) {
@@ -212,4 +566,145 @@ func TestCypherReturn(t *testing.T) {
 		str := query.ToStringWithTenant("TENANT")
 		assert.Equal(t, "MATCH (n:Person{foo:'bar',tenant:'TENANT'})-[r{tenant:'TENANT'}]->(o:Person{tenant:'TENANT'}) RETURN n.foo", str)
 	})
+	t.Run("optional match test", func(t *testing.T) {
+		s := "MATCH (m:Movie) OPTIONAL MATCH (m)<-[:DIRECTED]-(d) RETURN m.title,d.name"
+		parser := NewParser(s)
+		query, err := parser.parseQuery()
+		assert.Nil(t, err)
+		str := query.ToStringWithTenant("TENANT")
+		// the tenant must stay inside the optional pattern, not in a WHERE clause
+		assert.Equal(t, "MATCH (m:Movie{tenant:'TENANT'}) OPTIONAL MATCH (m{tenant:'TENANT'})<-[:DIRECTED{tenant:'TENANT'}]-(d{tenant:'TENANT'}) RETURN m.title,d.name", str)
+		assert.Equal(t, "MATCH (m:Movie) OPTIONAL MATCH (m)<-[:DIRECTED]-(d) RETURN m.title,d.name", query.ToString())
+	})
+	t.Run("with test", func(t *testing.T) {
+		s := "MATCH (a:Person)-[:ACTED_IN]->(m:Movie) WITH a, count(m) AS movies WHERE movies > 3 MATCH (a)-[:DIRECTED]->(d) RETURN a.name, movies, d"
+		parser := NewParser(s)
+		query, err := parser.Parse()
+		assert.Nil(t, err)
+		str := query.ToStringWithTenant("TENANT")
+		assert.Equal(t, "MATCH (a:Person{tenant:'TENANT'})-[:ACTED_IN{tenant:'TENANT'}]->(m:Movie{tenant:'TENANT'}) WITH a,count(m) AS movies WHERE movies > 3 MATCH (a{tenant:'TENANT'})-[:DIRECTED{tenant:'TENANT'}]->(d{tenant:'TENANT'}) RETURN a.name,movies,d", str)
+	})
+	t.Run("quoted names test", func(t *testing.T) {
+		// a quoted name must not comment out the tenant property
+		for _, s := range []string{
+			`MATCH (n:'Person) MATCH (m) RETURN m //') RETURN n`,
+			`MATCH (n{'a}) MATCH (m) RETURN m //':'b'}) RETURN n`,
+			`MATCH (n)-['r]->(m) RETURN m //']->(o) RETURN n`,
+			"MATCH (n:`Secret`) RETURN n",
+		} {
+			query, err := NewParser(s).Parse()
+			assert.NotNil(t, err, s)
+			if query != nil {
+				assert.NotContains(t, query.ToStringWithTenant("T1"), "//")
+			}
+		}
+	})
+	t.Run("escape test", func(t *testing.T) {
+		// a trailing backslash must not escape the closing quote
+		s := `MATCH (n:Person{name:'x\\'}) RETURN n`
+		parser := NewParser(s)
+		query, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.Equal(t, `MATCH (n:Person{name:'x\\'}) RETURN n`, query.ToString())
+
+		// an escaped quote does not end the string
+		_, err = NewParser(`MATCH (n:Person{name:'x\'}) RETURN n`).Parse()
+		assert.NotNil(t, err)
+	})
+	t.Run("escape round trip test", func(t *testing.T) {
+		s := `MATCH (n:Person{name:'O\'Brien'}) WHERE n.bio CONTAINS 'a\\b\n' RETURN n.name`
+		query, err := NewParser(s).Parse()
+		assert.Nil(t, err)
+		assert.Equal(t, "O'Brien", query.MatchNode.Props["name"])
+
+		rendered := query.ToString()
+		again, err := NewParser(rendered).Parse()
+		assert.Nil(t, err)
+		// the same values are read back (the positions of the tokens differing)
+		assert.Equal(t, query.MatchNode.Props, again.MatchNode.Props)
+		assert.Equal(t, query.Where.(*CypherBinaryExpression).Right, again.Where.(*CypherBinaryExpression).Right)
+		assert.Equal(t, rendered, again.ToString())
+	})
+	t.Run("union test", func(t *testing.T) {
+		s := "MATCH (a:Person) RETURN a.name AS name LIMIT 5000 UNION ALL MATCH (m:Movie) RETURN m.title AS name LIMIT 10"
+		parser := NewParser(s)
+		query, err := parser.Parse()
+		assert.Nil(t, err)
+		query.CapRows(100)
+		str := query.ToStringWithTenant("TENANT")
+		assert.Equal(t, "MATCH (a:Person{tenant:'TENANT'}) RETURN a.name AS name LIMIT 100 UNION ALL MATCH (m:Movie{tenant:'TENANT'}) RETURN m.title AS name LIMIT 10", str)
+	})
+	t.Run("where test", func(t *testing.T) {
+		s := "MATCH (m:Movie) OPTIONAL MATCH (m)<-[:DIRECTED]-(d) WHERE d.name STARTS WITH 'Lana' RETURN m.title,d.name"
+		parser := NewParser(s)
+		query, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.False(t, query.UsesRegex())
+		str := query.ToStringWithTenant("TENANT")
+		assert.Equal(t, "MATCH (m:Movie{tenant:'TENANT'}) OPTIONAL MATCH (m{tenant:'TENANT'})<-[:DIRECTED{tenant:'TENANT'}]-(d{tenant:'TENANT'}) WHERE d.name STARTS WITH 'Lana' RETURN m.title,d.name", str)
+	})
+	t.Run("pattern predicate test", func(t *testing.T) {
+		s := "MATCH (p:Person) WHERE NOT (p)-[:DIRECTED]->() AND EXISTS { MATCH (p)-[:ACTED_IN]->(m) WHERE m.released > 2000 } RETURN p.name"
+		parser := NewParser(s)
+		query, err := parser.Parse()
+		assert.Nil(t, err)
+		str := query.ToStringWithTenant("TENANT")
+		assert.Equal(t, "MATCH (p:Person{tenant:'TENANT'}) WHERE NOT (p{tenant:'TENANT'})-[:DIRECTED{tenant:'TENANT'}]->({tenant:'TENANT'}) AND EXISTS { MATCH (p{tenant:'TENANT'})-[:ACTED_IN{tenant:'TENANT'}]->(m{tenant:'TENANT'}) WHERE m.released > 2000 } RETURN p.name", str)
+	})
+	t.Run("parse error position test", func(t *testing.T) {
+		s := "MATCH (a:Person)\nWHERE a.born = RETURN a"
+		parser := NewParser(s)
+		_, err := parser.Parse()
+		parseErr, ok := err.(*ParseError)
+		assert.True(t, ok)
+		assert.Equal(t, 32, parseErr.Pos)
+		assert.Equal(t, 2, parseErr.Line)
+		assert.Equal(t, 16, parseErr.Column)
+		assert.Equal(t, "expected an expression. Got 'RETURN' (line 2, column 16)", err.Error())
+
+		// an unscanned token keeps its position
+		parser = NewParser("MATCH (a:Person) RETRN a")
+		_, err = parser.Parse()
+		assert.Equal(t, 18, err.(*ParseError).Column)
+	})
+	t.Run("variables test", func(t *testing.T) {
+		s := "MATCH (a:Person)-[r:ACTED_IN]->(m) WHERE EXISTS { MATCH (m)<--(d) } WITH a, count(m) AS movies RETURN a, movies UNION MATCH (b)-->(c) RETURN b AS a, count(c) AS movies"
+		parser := NewParser(s)
+		query, err := parser.Parse()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "m", "movies", "r"}, query.Variables())
+	})
+	t.Run("variable-length relationship test", func(t *testing.T) {
+		s := "MATCH (a:Person)-[:KNOWS*1..3]->(b:Person{name:'Tom'}) RETURN count(*)"
+		parser := NewParser(s)
+		query, err := parser.Parse()
+		assert.Nil(t, err)
+		str := query.ToStringWithTenant("TENANT")
+		assert.Equal(t, "MATCH (a:Person{tenant:'TENANT'})-[:KNOWS*1..3{tenant:'TENANT'}]->(b:Person{name:'Tom',tenant:'TENANT'}) RETURN count(*)", str)
+	})
+	t.Run("fingerprint test", func(t *testing.T) {
+		fingerprint := func(s string) string {
+			query, err := NewParser(s).Parse()
+			assert.Nil(t, err)
+			return query.Fingerprint()
+		}
+
+		expected := "MATCH (v1:Movie{released:?}) WHERE v1.title STARTS WITH ? AND v1.rating > ? RETURN v1.title"
+		assert.Equal(t, expected, fingerprint("MATCH (m:Movie{released:1999}) WHERE m.title STARTS WITH 'The' AND m.rating > 7.5 RETURN m.title"))
+		assert.Equal(t, expected, fingerprint("match (m:Movie {released: 2003}) where m.title starts with 'Matrix' and m.rating > 8 return m.title"))
+		// the variables are renamed
+		assert.Equal(t, expected, fingerprint("MATCH (a:Movie{released:2003}) WHERE a.title STARTS WITH 'Matrix' AND a.rating > -1 RETURN a.title"))
+
+		assert.Equal(t, "MATCH (v1:Movie) WHERE v1.title IN [?] RETURN v1", fingerprint("MATCH (m:Movie) WHERE m.title IN ['a', 'b', 'c'] RETURN m"))
+		assert.Equal(t, "MATCH (v1:Person)-[:KNOWS*?..?]->(v2) RETURN count(*)", fingerprint("MATCH (a:Person)-[:KNOWS*1..3]->(b) RETURN count(*)"))
+		assert.Equal(t, "MATCH (v1:Person)-[:ACTED_IN]->(v2) WITH v1,count(v2) AS v3 WHERE v3 > ? RETURN v1.name AS name,v3 AS n LIMIT ? UNION MATCH (v1:Movie) RETURN v1.title AS name,v1.released AS n LIMIT ?",
+			fingerprint("MATCH (p:Person)-[:ACTED_IN]->(m) WITH p, count(m) AS movies WHERE movies > 3 RETURN p.name AS name, movies AS n LIMIT 10 UNION MATCH (m:Movie) RETURN m.title AS name, m.released AS n LIMIT 5"))
+
+		// the escaped quotes are part of the literal values
+		assert.Equal(t, "MATCH (v1) WHERE v1.name = ? AND v1.bio IS NOT NULL RETURN v1.name", fingerprint(`MATCH (n) WHERE n.name = 'O\'Brien' AND n.bio IS NOT NULL RETURN n.name`))
+
+		// the shape, the labels and the returned columns are kept
+		assert.NotEqual(t, fingerprint("MATCH (m:Movie) RETURN m.title"), fingerprint("MATCH (m:Person) RETURN m.title"))
+		assert.NotEqual(t, fingerprint("MATCH (m:Movie) RETURN m.title"), fingerprint("MATCH (m:Movie) RETURN m.released"))
+	})
 }
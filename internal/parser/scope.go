@@ -0,0 +1,180 @@
+package parser
+
+// addMatch adds a MATCH / OPTIONAL MATCH clause to the current stage of the query
+func (q *CypherQuery) addMatch(m CypherMatch) {
+	if len(q.With) == 0 {
+		q.Matches = append(q.Matches, m)
+		return
+	}
+	with := &q.With[len(q.With)-1]
+	with.Matches = append(with.Matches, m)
+}
+
+// validate verifies the query, and the queries combined with it, once parsed
+func (q *CypherQuery) validate() *ParseError {
+	if err := q.checkScope(); err != nil {
+		return err
+	}
+	if len(q.Unions) == 0 {
+		return nil
+	}
+
+	columns := q.Return.columns()
+	for _, u := range q.Unions {
+		if len(q.Return) == 0 || len(u.Query.Return) == 0 {
+			return newSemanticError(u.Pos, "each query of a UNION must have a RETURN clause")
+		}
+		if err := u.Query.checkScope(); err != nil {
+			return err
+		}
+		for _, ret := range u.Query.Return {
+			if !columns[ret.Name()] {
+				return newSemanticError(ret.Pos, "all queries of a UNION must return the same columns ('%s' is not returned by the first query)", ret.Name())
+			}
+		}
+		if len(u.Query.Return.columns()) != len(columns) {
+			return newSemanticError(u.Pos, "all queries of a UNION must return the same columns")
+		}
+	}
+	return nil
+}
+
+// columns returns the names of the returned columns
+func (r CypherReturn) columns() map[string]bool {
+	columns := map[string]bool{}
+	for _, ret := range r {
+		columns[ret.Name()] = true
+	}
+	return columns
+}
+
+// checkScope verifies that the WHERE, WITH and RETURN clauses only refer to variables in scope.
+// After a WITH, only the variables it projects are still in scope.
+func (q *CypherQuery) checkScope() *ParseError {
+	scope := map[string]bool{}
+	first := CypherMatch{Node: q.MatchNode, Relationship: q.Relationship, Where: q.Where}
+	if err := checkMatchScope(scope, &first); err != nil {
+		return err
+	}
+	for _, m := range q.Matches {
+		if err := checkMatchScope(scope, &m); err != nil {
+			return err
+		}
+	}
+
+	for _, w := range q.With {
+		next := map[string]bool{}
+		for _, proj := range w.Projections {
+			if err := checkProjectionScope(scope, &proj); err != nil {
+				return err
+			}
+			if proj.Alias == nil && (proj.Property != nil || proj.Function != nil) {
+				return newSemanticError(proj.Pos, "expression '%s' in WITH must be aliased (use AS)", proj.ToString())
+			}
+			if next[proj.Name()] {
+				return newSemanticError(proj.Pos, "variable '%s' is projected twice in WITH", proj.Name())
+			}
+			next[proj.Name()] = true
+		}
+		scope = next
+
+		if w.Where != nil {
+			if err := checkExpressionScope(scope, w.Where); err != nil {
+				return err
+			}
+		}
+		for _, m := range w.Matches {
+			if err := checkMatchScope(scope, &m); err != nil {
+				return err
+			}
+		}
+	}
+
+	columns := map[string]bool{}
+	for _, ret := range q.Return {
+		if err := checkProjectionScope(scope, &ret); err != nil {
+			return err
+		}
+		if columns[ret.Name()] {
+			return newSemanticError(ret.Pos, "column '%s' is returned twice", ret.Name())
+		}
+		columns[ret.Name()] = true
+	}
+	return nil
+}
+
+// checkMatchScope adds the variables bound by the MATCH clause to the scope, and verifies its WHERE clause
+func checkMatchScope(scope map[string]bool, m *CypherMatch) *ParseError {
+	addPatternVariables(scope, &m.Node, m.Relationship)
+	if m.Where == nil {
+		return nil
+	}
+	return checkExpressionScope(scope, m.Where)
+}
+
+// addPatternVariables adds the variables bound by a pattern to the scope
+func addPatternVariables(scope map[string]bool, node *CypherNode, rel *CypherRelationShip) {
+	if node.VariableName != nil {
+		scope[*node.VariableName] = true
+	}
+	if rel == nil {
+		return
+	}
+	if rel.Props != nil && rel.Props.VariableName != nil {
+		scope[*rel.Props.VariableName] = true
+	}
+	if rel.Target.VariableName != nil {
+		scope[*rel.Target.VariableName] = true
+	}
+}
+
+func checkProjectionScope(scope map[string]bool, proj *CypherVariableReturn) *ParseError {
+	if proj.VariableName == "*" {
+		if proj.Function == nil || *proj.Function != "count" || proj.Property != nil {
+			return newSemanticError(proj.Pos, "'*' can only be used as count(*)")
+		}
+		return nil
+	}
+	if !scope[proj.VariableName] {
+		return newSemanticError(proj.Pos, "variable '%s' is not defined", proj.VariableName)
+	}
+	return nil
+}
+
+// checkExpressionScope verifies that the expression only refers to variables in scope. A pattern predicate cannot
+// introduce new variables, while an EXISTS subquery can (but only for itself)
+func checkExpressionScope(scope map[string]bool, e CypherExpression) *ParseError {
+	var err *ParseError
+	WalkExpression(e, func(e CypherExpression) bool {
+		if err != nil {
+			return false
+		}
+		switch e := e.(type) {
+		case *CypherPropertyExpression:
+			if !scope[e.VariableName] {
+				err = newSemanticError(e.Pos, "variable '%s' is not defined", e.VariableName)
+			}
+		case *CypherPatternExpression:
+			patternScope := map[string]bool{}
+			addPatternVariables(patternScope, &e.Node, e.Relationship)
+			for v := range patternScope {
+				if !scope[v] {
+					err = newSemanticError(e.Pos, "variable '%s' is not defined (a pattern predicate cannot introduce new variables)", v)
+				}
+			}
+		case *CypherExistsExpression:
+			subqueryScope := map[string]bool{}
+			for v := range scope {
+				subqueryScope[v] = true
+			}
+			for _, m := range e.Matches {
+				if err = checkMatchScope(subqueryScope, &m); err != nil {
+					break
+				}
+			}
+			return false
+		}
+		return true
+	})
+	return err
+}
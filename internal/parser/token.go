@@ -7,6 +7,10 @@ type Token int
 type TokenInfo struct {
 	Token   Token
 	Literal string
+	// Quoted is true if the literal was a quoted string, i.e. 'foo'
+	Quoted bool
+	// Pos is the position of the token in the scanned string, in runes
+	Pos int
 }
 
 // TokenLookup is a map, useful for printing readable names of the tokens.
@@ -27,6 +31,14 @@ var TokenLookup = map[Token]string{
 	DOUBLECOLON:         ":",
 	COMMA:               ",",
 	DOT:                 ".",
+	ASTERISK:            "*",
+	EQUAL:               "=",
+	NOT_EQUAL:           "<>",
+	LESS_THAN:           "<",
+	GREATER_THAN:        ">",
+	LESS_OR_EQUAL:       "<=",
+	GREATER_OR_EQUAL:    ">=",
+	REGEX_MATCH:         "=~",
 }
 
 // String prints a human readable string name for a given token.
@@ -63,10 +75,36 @@ const (
 	DOUBLECOLON
 	QUOTE
 	DOT
+	ASTERISK
+
+	// Comparison operators
+	EQUAL
+	NOT_EQUAL
+	LESS_THAN
+	GREATER_THAN
+	LESS_OR_EQUAL
+	GREATER_OR_EQUAL
+	REGEX_MATCH
 
 	// Keywords
 	MATCH
 	WHERE
 	RETURN
 	NOT
+	OPTIONAL
+	WITH
+	AS
+	DISTINCT
+	AND
+	OR
+	LIMIT
+	UNION
+	ALL
+	IN
+	STARTS
+	ENDS
+	CONTAINS
+	IS
+	NULL
+	EXISTS
 )
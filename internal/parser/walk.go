@@ -0,0 +1,103 @@
+package parser
+
+import "sort"
+
+// Queries returns the query and the queries combined with it by a UNION.
+// The other walk functions only go through a single query, and not through the queries combined with it.
+func (q *CypherQuery) Queries() []*CypherQuery {
+	queries := []*CypherQuery{q}
+	for i := range q.Unions {
+		queries = append(queries, &q.Unions[i].Query)
+	}
+	return queries
+}
+
+// MatchClauses returns all the MATCH / OPTIONAL MATCH clauses of the query, starting with the leading MATCH
+func (q *CypherQuery) MatchClauses() []CypherMatch {
+	matches := []CypherMatch{{Node: q.MatchNode, Relationship: q.Relationship, Where: q.Where}}
+	matches = append(matches, q.Matches...)
+	for _, w := range q.With {
+		matches = append(matches, w.Matches...)
+	}
+	return matches
+}
+
+// Variables returns the (sorted) variables bound by the MATCH clauses and the WITH clauses of the query, and of the
+// queries combined with it. The variables only bound inside an EXISTS subquery are not returned.
+func (q *CypherQuery) Variables() []string {
+	set := map[string]bool{}
+	for _, query := range q.Queries() {
+		for _, m := range query.MatchClauses() {
+			nodes := []*CypherNode{&m.Node}
+			if m.Relationship != nil {
+				if m.Relationship.Props != nil {
+					nodes = append(nodes, m.Relationship.Props)
+				}
+				nodes = append(nodes, &m.Relationship.Target)
+			}
+			for _, n := range nodes {
+				if n.VariableName != nil {
+					set[*n.VariableName] = true
+				}
+			}
+		}
+		for _, w := range query.With {
+			for _, proj := range w.Projections {
+				set[proj.Name()] = true
+			}
+		}
+	}
+
+	variables := make([]string, 0, len(set))
+	for v := range set {
+		variables = append(variables, v)
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// WhereExpressions returns the expressions of all the WHERE clauses of the query
+func (q *CypherQuery) WhereExpressions() []CypherExpression {
+	expressions := []CypherExpression{}
+	for _, m := range q.MatchClauses() {
+		if m.Where != nil {
+			expressions = append(expressions, m.Where)
+		}
+	}
+	for _, w := range q.With {
+		if w.Where != nil {
+			expressions = append(expressions, w.Where)
+		}
+	}
+	return expressions
+}
+
+// Projections returns the elements projected by the WITH clauses and by the RETURN clause of the query
+func (q *CypherQuery) Projections() []CypherVariableReturn {
+	projections := []CypherVariableReturn{}
+	for _, w := range q.With {
+		projections = append(projections, w.Projections...)
+	}
+	return append(projections, q.Return...)
+}
+
+// WalkPatterns calls fn for the pattern of each MATCH clause, pattern predicate and EXISTS subquery of the query.
+// rel is nil for a single node pattern.
+func (q *CypherQuery) WalkPatterns(fn func(node *CypherNode, rel *CypherRelationShip)) {
+	for _, m := range q.MatchClauses() {
+		fn(&m.Node, m.Relationship)
+	}
+	for _, where := range q.WhereExpressions() {
+		WalkExpression(where, func(e CypherExpression) bool {
+			switch e := e.(type) {
+			case *CypherPatternExpression:
+				fn(&e.Node, e.Relationship)
+			case *CypherExistsExpression:
+				for _, m := range e.Matches {
+					fn(&m.Node, m.Relationship)
+				}
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nzin/lexneo4j/internal/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of the elements of a query checked by the policy
+const (
+	KindLabel            = "label"
+	KindRelationshipType = "relationshipType"
+	KindProperty         = "property"
+)
+
+// Policy allows or denies the labels, relationship types and property keys used by a query, per caller role.
+//
+// A policy file looks like (JSON can be used as well):
+//
+//	roles:
+//	  anonymous:
+//	    labels:
+//	      allow: [Movie, Person]
+//	    relationshipTypes:
+//	      deny: [FOLLOWS]
+//	    properties:
+//	      deny: [ssn]
+type Policy struct {
+	Roles map[string]Rule `yaml:"roles" json:"roles"`
+}
+
+// Rule is the access of a role
+type Rule struct {
+	Labels            Access `yaml:"labels" json:"labels"`
+	RelationshipTypes Access `yaml:"relationshipTypes" json:"relationshipTypes"`
+	Properties        Access `yaml:"properties" json:"properties"`
+}
+
+// Access lists the allowed and denied elements of a kind.
+// An element is allowed if it is not denied and, when the allow list is not empty, if it is in the allow list.
+type Access struct {
+	Allow []string `yaml:"allow" json:"allow"`
+	Deny  []string `yaml:"deny" json:"deny"`
+}
+
+// Denial explains why a query is denied
+type Denial struct {
+	// Kind is one of KindLabel, KindRelationshipType or KindProperty
+	Kind string
+	// Element is the denied label, relationship type or property key (empty for an unlabeled node, an untyped
+	// relationship or a whole entity returned with all its properties)
+	Element string
+	Roles   []string
+}
+
+func (d *Denial) Error() string {
+	roles := strings.Join(d.Roles, ",")
+	if d.Element == "" {
+		switch d.Kind {
+		case KindLabel:
+			return fmt.Sprintf("unlabeled nodes are not allowed for roles [%s]", roles)
+		case KindRelationshipType:
+			return fmt.Sprintf("untyped relationships are not allowed for roles [%s]", roles)
+		case KindProperty:
+			return fmt.Sprintf("returning whole nodes or relationships (or their keys) is not allowed for roles [%s], return their properties instead", roles)
+		}
+	}
+	return fmt.Sprintf("%s '%s' is not allowed for roles [%s]", d.Kind, d.Element, roles)
+}
+
+// LoadFile loads a policy from a YAML or JSON file
+func LoadFile(path string) (*Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(content)
+}
+
+// Load loads a policy from YAML or JSON content
+func Load(content []byte) (*Policy, error) {
+	policy := Policy{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %v", err)
+	}
+	return &policy, nil
+}
+
+// Evaluate checks the query (and the queries combined with it) against the rules of the caller roles.
+// An element is allowed if at least one of the roles allows it. It returns nil if the query is allowed, or if there
+// is no policy.
+func (p *Policy) Evaluate(query *parser.CypherQuery, roles []string) *Denial {
+	if p == nil {
+		return nil
+	}
+
+	rules := []Rule{}
+	for _, role := range roles {
+		if rule, ok := p.Roles[role]; ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	checks := []check{}
+	for _, q := range query.Queries() {
+		labels, types, properties := elements(q)
+		checks = append(checks,
+			check{KindLabel, labels, func(r *Rule) *Access { return &r.Labels }},
+			check{KindRelationshipType, types, func(r *Rule) *Access { return &r.RelationshipTypes }},
+			check{KindProperty, properties, func(r *Rule) *Access { return &r.Properties }},
+		)
+	}
+
+	for _, check := range checks {
+		for _, element := range check.elements {
+			allowed := false
+			for i := range rules {
+				if check.access(&rules[i]).allows(check.kind, element) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return &Denial{Kind: check.kind, Element: element, Roles: roles}
+			}
+		}
+	}
+	return nil
+}
+
+// check is a list of elements of a kind to check, against the access of the rules for this kind
+type check struct {
+	kind     string
+	elements []string
+	access   func(r *Rule) *Access
+}
+
+func (a *Access) allows(kind string, element string) bool {
+	// a whole entity exposes all its properties, allowed only if none of them is restricted
+	if kind == KindProperty && element == "" {
+		return len(a.Allow) == 0 && len(a.Deny) == 0
+	}
+	for _, denied := range a.Deny {
+		if element == denied {
+			return false
+		}
+	}
+	if len(a.Allow) == 0 {
+		return true
+	}
+	for _, allowed := range a.Allow {
+		if element == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// elements returns the (sorted) labels, relationship types and property keys used by a single query.
+// An unlabeled node (or an untyped relationship) is returned as an empty label (or type), unless its variable is
+// labeled (or typed) somewhere else in the query. A whole entity returned by the query is returned as an empty
+// property key, last.
+func elements(q *parser.CypherQuery) (labels []string, types []string, properties []string) {
+	labelSet := map[string]bool{}
+	typeSet := map[string]bool{}
+	propertySet := map[string]bool{}
+
+	// first pass: the labeled (or typed) variables
+	typed := map[string]bool{}
+	q.WalkPatterns(func(node *parser.CypherNode, rel *parser.CypherRelationShip) {
+		for _, n := range patternNodes(node, rel) {
+			if n.VariableName != nil && n.TypeName != nil {
+				typed[*n.VariableName] = true
+			}
+		}
+	})
+
+	q.WalkPatterns(func(node *parser.CypherNode, rel *parser.CypherRelationShip) {
+		for i, n := range patternNodes(node, rel) {
+			set := labelSet
+			if rel != nil && i == 1 {
+				set = typeSet
+			}
+			switch {
+			case n.TypeName != nil:
+				set[*n.TypeName] = true
+			case n.VariableName == nil || !typed[*n.VariableName]:
+				set[""] = true
+			}
+			for k := range n.Props {
+				propertySet[k] = true
+			}
+		}
+	})
+
+	for _, proj := range q.Projections() {
+		if proj.Property != nil {
+			propertySet[*proj.Property] = true
+		}
+	}
+	for _, where := range q.WhereExpressions() {
+		parser.WalkExpression(where, func(e parser.CypherExpression) bool {
+			if property, ok := e.(*parser.CypherPropertyExpression); ok && property.Property != nil {
+				propertySet[*property.Property] = true
+			}
+			return true
+		})
+	}
+	properties = sortedKeys(propertySet)
+	// after the property keys, as the denial of a named key is clearer
+	if returnsEntity(q) {
+		properties = append(properties, "")
+	}
+
+	return sortedKeys(labelSet), sortedKeys(typeSet), properties
+}
+
+// entityFunctions are the functions exposing the properties of the entities they get: collect, min and max return
+// them, keys returns their property keys
+var entityFunctions = map[string]bool{"collect": true, "min": true, "max": true, "keys": true}
+
+// returnsEntity returns true if the RETURN clause of a single query returns a whole node or relationship, with all its
+// properties, i.e. "RETURN p", "RETURN collect(p)", "RETURN keys(p)" or "WITH p AS x RETURN x"
+func returnsEntity(q *parser.CypherQuery) bool {
+	entities := map[string]bool{}
+	addEntities(entities, q.MatchNode, q.Relationship)
+	for _, m := range q.Matches {
+		addEntities(entities, m.Node, m.Relationship)
+	}
+	for _, w := range q.With {
+		// after a WITH, only its projections are in scope
+		projected := map[string]bool{}
+		for _, proj := range w.Projections {
+			projected[proj.Name()] = exposesEntity(proj, entities) && (proj.Function == nil || *proj.Function != "keys")
+		}
+		entities = projected
+		for _, m := range w.Matches {
+			addEntities(entities, m.Node, m.Relationship)
+		}
+	}
+
+	for _, ret := range q.Return {
+		if exposesEntity(ret, entities) {
+			return true
+		}
+	}
+	return false
+}
+
+// addEntities adds the variables of the nodes and relationships of a pattern
+func addEntities(entities map[string]bool, node parser.CypherNode, rel *parser.CypherRelationShip) {
+	for _, n := range patternNodes(&node, rel) {
+		if n.VariableName != nil {
+			entities[*n.VariableName] = true
+		}
+	}
+}
+
+// exposesEntity returns true if the projection exposes the properties of an entity variable
+func exposesEntity(proj parser.CypherVariableReturn, entities map[string]bool) bool {
+	if proj.Property != nil || !entities[proj.VariableName] {
+		return false
+	}
+	return proj.Function == nil || entityFunctions[*proj.Function]
+}
+
+// patternNodes returns the nodes of a pattern: the node, and if there is a relationship, the relationship
+// (as a node, an untyped one if it has no brackets) and its target
+func patternNodes(node *parser.CypherNode, rel *parser.CypherRelationShip) []*parser.CypherNode {
+	if rel == nil {
+		return []*parser.CypherNode{node}
+	}
+	props := rel.Props
+	if props == nil {
+		props = &parser.CypherNode{}
+	}
+	return []*parser.CypherNode{node, props, &rel.Target}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
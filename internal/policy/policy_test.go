@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/nzin/lexneo4j/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `
+roles:
+  anonymous:
+    labels:
+      allow: [Movie, Person]
+    relationshipTypes:
+      deny: [FOLLOWS]
+    properties:
+      deny: [ssn]
+  admin:
+    properties:
+      allow: []
+`
+
+func evaluate(t *testing.T, p *Policy, s string, roles ...string) *Denial {
+	query, err := parser.NewParser(s).Parse()
+	assert.Nil(t, err)
+	return p.Evaluate(query, roles)
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := Load([]byte(testPolicy))
+	assert.Nil(t, err)
+
+	t.Run("allowed query", func(t *testing.T) {
+		s := "MATCH (m:Movie) OPTIONAL MATCH (m)<-[:DIRECTED]-(d:Person) WHERE d.born > 1970 RETURN m.title, d.name, count(d), labels(d)"
+		assert.Nil(t, evaluate(t, p, s, "anonymous"))
+		s = "MATCH (p:Person) WITH p MATCH (p)-[:ACTED_IN]->(m:Movie) RETURN p.name, m.title"
+		assert.Nil(t, evaluate(t, p, s, "anonymous"))
+	})
+
+	t.Run("denied label", func(t *testing.T) {
+		s := "MATCH (m:Movie) WHERE EXISTS { MATCH (m)<-[:ACTED_IN]-(:Secret) } RETURN m"
+		denial := evaluate(t, p, s, "anonymous")
+		assert.NotNil(t, denial)
+		assert.Equal(t, KindLabel, denial.Kind)
+		assert.Equal(t, "Secret", denial.Element)
+		assert.Equal(t, "label 'Secret' is not allowed for roles [anonymous]", denial.Error())
+	})
+
+	t.Run("denied unlabeled node", func(t *testing.T) {
+		s := "MATCH (m:Movie)--(n) RETURN n"
+		denial := evaluate(t, p, s, "anonymous")
+		assert.NotNil(t, denial)
+		assert.Equal(t, KindLabel, denial.Kind)
+		assert.Equal(t, "", denial.Element)
+	})
+
+	t.Run("denied relationship type in a union", func(t *testing.T) {
+		s := "MATCH (m:Movie) RETURN m.title AS x UNION MATCH (p:Person)-[:FOLLOWS]->(:Person) RETURN p.name AS x"
+		denial := evaluate(t, p, s, "anonymous")
+		assert.NotNil(t, denial)
+		assert.Equal(t, KindRelationshipType, denial.Kind)
+		assert.Equal(t, "FOLLOWS", denial.Element)
+	})
+
+	t.Run("denied property", func(t *testing.T) {
+		for _, s := range []string{
+			"MATCH (p:Person) RETURN p.ssn",
+			"MATCH (p:Person{ssn:'123'}) RETURN p",
+			"MATCH (p:Person) WHERE p.ssn STARTS WITH '1' RETURN p",
+			"MATCH (p:Person) WITH collect(p.ssn) AS s RETURN s",
+		} {
+			denial := evaluate(t, p, s, "anonymous")
+			assert.NotNil(t, denial, s)
+			assert.Equal(t, KindProperty, denial.Kind)
+			assert.Equal(t, "ssn", denial.Element)
+		}
+	})
+
+	t.Run("denied whole entity", func(t *testing.T) {
+		// they would return the denied properties
+		for _, s := range []string{
+			"MATCH (p:Person) RETURN p",
+			"MATCH (p:Person) RETURN keys(p)",
+			"MATCH (m:Movie)<-[r:ACTED_IN]-(:Person) RETURN m.title, r",
+			"MATCH (p:Person) WITH collect(p) AS ps RETURN ps",
+			"MATCH (p:Person) WITH p AS x RETURN x.name, x",
+		} {
+			denial := evaluate(t, p, s, "anonymous")
+			assert.NotNil(t, denial, s)
+			assert.Equal(t, KindProperty, denial.Kind)
+			assert.Equal(t, "", denial.Element)
+		}
+		assert.Nil(t, evaluate(t, p, "MATCH (p:Person) RETURN p", "anonymous", "admin"))
+		assert.Nil(t, evaluate(t, p, "MATCH (p:Person) WITH keys(p) AS k RETURN count(k)", "anonymous"))
+	})
+
+	t.Run("backtick names", func(t *testing.T) {
+		_, err := parser.NewParser("MATCH (n:`Secret`) RETURN n.name").Parse()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("allowed by one of the roles", func(t *testing.T) {
+		s := "MATCH (p:Person)-[:FOLLOWS]->(o) RETURN p.ssn, o"
+		assert.NotNil(t, evaluate(t, p, s, "anonymous"))
+		assert.Nil(t, evaluate(t, p, s, "anonymous", "admin"))
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		s := "MATCH (m:Movie) RETURN m"
+		assert.NotNil(t, evaluate(t, p, s, "guest"))
+	})
+
+	t.Run("no policy", func(t *testing.T) {
+		var noPolicy *Policy
+		s := "MATCH (n) RETURN n"
+		assert.Nil(t, evaluate(t, noPolicy, s, "guest"))
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		_, err := Load([]byte(`{"roles": {"anonymous": {"label": {"allow": ["Movie"]}}}}`))
+		assert.NotNil(t, err)
+	})
+}
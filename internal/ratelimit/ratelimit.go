@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// Config configures the limits of the callers. A zero limit means no limit.
+type Config struct {
+	// Rate is the number of requests per second allowed per caller, on average
+	Rate float64
+	// Burst is the number of requests a caller can send at once (at least 1 if there is a rate)
+	Burst int
+	// MaxConcurrentPerCaller is the maximum number of concurrent requests of a caller on the ConcurrencyURLs
+	MaxConcurrentPerCaller int
+	// MaxConcurrent is the maximum number of concurrent requests of all the callers on the ConcurrencyURLs
+	MaxConcurrent int
+	// ConcurrencyURLs are the paths of the requests running queries, whose concurrency is limited
+	ConcurrencyURLs []string
+	// ExemptURLs are the paths (and the paths below them) that are not limited at all
+	ExemptURLs []string
+}
+
+// sweepInterval is how often the limiters of the idle callers are forgotten
+const sweepInterval = time.Minute
+
+// Limiter is a negroni middleware rejecting with a 429 the requests of the callers sending too many requests
+// (token bucket per caller), or running too many queries at once (per caller and globally). The callers are
+// identified by their authenticated identity, or by their IP if they are not authenticated.
+type Limiter struct {
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	limiters  map[string]*rate.Limiter
+	inFlight  map[string]int
+	total     int
+	lastSweep time.Time
+}
+
+// NewLimiter returns the middleware, or nil if there are no limits
+func NewLimiter(config Config) *Limiter {
+	if config.Rate <= 0 && config.MaxConcurrentPerCaller <= 0 && config.MaxConcurrent <= 0 {
+		return nil
+	}
+	if config.Rate > 0 && config.Burst < 1 {
+		config.Burst = 1
+	}
+	return &Limiter{
+		config:   config,
+		now:      time.Now,
+		limiters: map[string]*rate.Limiter{},
+		inFlight: map[string]int{},
+	}
+}
+
+// ServeHTTP rejects the requests above the limits with a 429 and a Retry-After header
+func (l *Limiter) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if matchesURL(l.config.ExemptURLs, r.URL.Path, true) {
+		next(rw, r)
+		return
+	}
+
+	caller := callerKey(r)
+	if delay := l.reserve(caller); delay > 0 {
+		l.reject(rw, r, caller, delay, "rate limit exceeded")
+		return
+	}
+
+	if matchesURL(l.config.ConcurrencyURLs, r.URL.Path, false) {
+		if message := l.acquire(caller); message != "" {
+			l.reject(rw, r, caller, time.Second, message)
+			return
+		}
+		defer l.release(caller)
+	}
+
+	next(rw, r)
+}
+
+// reserve takes a token from the bucket of the caller, returning how long to wait for one if it is empty
+func (l *Limiter) reserve(caller string) time.Duration {
+	if l.config.Rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	limiter, ok := l.limiters[caller]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(l.config.Rate), l.config.Burst)
+		l.limiters[caller] = limiter
+	}
+	reservation := limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		// the request is rejected, so it must not consume the token
+		reservation.CancelAt(now)
+	}
+	return delay
+}
+
+// sweep forgets the limiters whose bucket is full again, as they are the same as new ones
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for caller, limiter := range l.limiters {
+		if limiter.TokensAt(now) >= float64(l.config.Burst) {
+			delete(l.limiters, caller)
+		}
+	}
+}
+
+// acquire counts a running request of the caller, returning why it is rejected if there are already too many
+func (l *Limiter) acquire(caller string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.MaxConcurrent > 0 && l.total >= l.config.MaxConcurrent {
+		return fmt.Sprintf("too many concurrent queries (maximum %d)", l.config.MaxConcurrent)
+	}
+	if l.config.MaxConcurrentPerCaller > 0 && l.inFlight[caller] >= l.config.MaxConcurrentPerCaller {
+		return fmt.Sprintf("too many concurrent queries for the caller (maximum %d)", l.config.MaxConcurrentPerCaller)
+	}
+	l.total++
+	l.inFlight[caller]++
+	return ""
+}
+
+func (l *Limiter) release(caller string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	if l.inFlight[caller]--; l.inFlight[caller] <= 0 {
+		delete(l.inFlight, caller)
+	}
+}
+
+// reject writes a 429 response, with the same payload as the API errors
+func (l *Limiter) reject(rw http.ResponseWriter, r *http.Request, caller string, retryAfter time.Duration, message string) {
+	logrus.WithFields(logrus.Fields{"caller": caller, "path": r.URL.Path}).Warnf("request rejected: %s", message)
+	rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(rw).Encode(map[string]string{"message": message})
+}
+
+// callerKey identifies the caller by its identity if it is authenticated, or by its IP
+func callerKey(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		return "caller:" + identity.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// matchesURL returns true if the path is one of the URLs, or below one of them if below is true
+func matchesURL(urls []string, path string, below bool) bool {
+	for _, u := range urls {
+		if path == u || (below && strings.HasPrefix(path, strings.TrimSuffix(u, "/")+"/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nzin/lexneo4j/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func request(path string, remoteAddr string, caller string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, nil)
+	r.RemoteAddr = remoteAddr
+	if caller != "" {
+		r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{Name: caller}))
+	}
+	return r
+}
+
+func serve(l *Limiter, r *http.Request) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	l.ServeHTTP(rw, r, func(http.ResponseWriter, *http.Request) {})
+	return rw
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(Config{Rate: 0.5, Burst: 2, ExemptURLs: []string{"/api/v1/health"}})
+	l.now = func() time.Time { return now }
+
+	t.Run("per caller", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "alice")).Code)
+		}
+		rw := serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "alice"))
+		assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+		assert.Equal(t, "2", rw.Header().Get("Retry-After"))
+		assert.Contains(t, rw.Body.String(), "rate limit exceeded")
+
+		// the other callers, even from the same IP, have their own bucket
+		assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "bob")).Code)
+		assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "")).Code)
+
+		// the rejected requests do not consume tokens
+		now = now.Add(2 * time.Second)
+		assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "alice")).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "alice")).Code)
+	})
+
+	t.Run("per IP", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/movies", "10.0.0.2:1234", "")).Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, serve(l, request("/api/v1/movies", "10.0.0.2:5678", "")).Code)
+		assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/movies", "10.0.0.3:1234", "")).Code)
+	})
+
+	t.Run("exempted urls", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/health", "10.0.0.2:1234", "")).Code)
+		}
+	})
+
+	t.Run("idle callers are forgotten", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "alice"))
+		assert.Len(t, l.limiters, 1)
+	})
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	l := NewLimiter(Config{MaxConcurrentPerCaller: 1, MaxConcurrent: 2, ConcurrencyURLs: []string{"/api/v1/cypher"}})
+
+	// run a blocked request per caller
+	release := make(chan struct{})
+	var running, done sync.WaitGroup
+	for _, caller := range []string{"alice", "bob"} {
+		running.Add(1)
+		done.Add(1)
+		go func(caller string) {
+			defer done.Done()
+			l.ServeHTTP(httptest.NewRecorder(), request("/api/v1/cypher", "10.0.0.1:1234", caller), func(http.ResponseWriter, *http.Request) {
+				running.Done()
+				<-release
+			})
+		}(caller)
+	}
+	running.Wait()
+
+	rw := serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "alice"))
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+	assert.Contains(t, rw.Body.String(), "too many concurrent queries (maximum 2)")
+
+	// the other urls are not limited
+	assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/cypher/validate", "10.0.0.1:1234", "alice")).Code)
+
+	close(release)
+	done.Wait()
+	assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "alice")).Code)
+	assert.Equal(t, 0, l.total)
+	assert.Empty(t, l.inFlight)
+
+	t.Run("per caller", func(t *testing.T) {
+		l := NewLimiter(Config{MaxConcurrentPerCaller: 1, ConcurrencyURLs: []string{"/api/v1/cypher"}})
+		var rw *httptest.ResponseRecorder
+		l.ServeHTTP(httptest.NewRecorder(), request("/api/v1/cypher", "10.0.0.1:1234", "alice"), func(http.ResponseWriter, *http.Request) {
+			rw = serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "alice"))
+			assert.Equal(t, http.StatusOK, serve(l, request("/api/v1/cypher", "10.0.0.1:1234", "bob")).Code)
+		})
+		assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+		assert.Contains(t, rw.Body.String(), "for the caller (maximum 1)")
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		assert.Nil(t, NewLimiter(Config{}))
+	})
+}
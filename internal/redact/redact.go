@@ -0,0 +1,230 @@
+package redact
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/parser"
+)
+
+// Redaction modes
+const (
+	// ModeStrip removes the redacted properties
+	ModeStrip = "strip"
+	// ModeMask replaces the value of the redacted properties with Mask
+	ModeMask = "mask"
+)
+
+// Mask is the value of a masked property
+const Mask = "[REDACTED]"
+
+// Redactor strips or masks label/property pairs (i.e. "Person.born") from the query results, including inside the
+// returned nodes, relationships (the label being the relationship type), paths, lists and maps
+type Redactor struct {
+	// properties are the redacted properties, per label
+	properties map[string]map[string]bool
+	mask       bool
+}
+
+// NewRedactor creates a Redactor for a list of "Label.property" pairs. It returns nil (no redaction) if the list is
+// empty.
+func NewRedactor(properties []string, mode string) (*Redactor, error) {
+	r := Redactor{
+		properties: map[string]map[string]bool{},
+	}
+	switch mode {
+	case ModeStrip:
+	case ModeMask:
+		r.mask = true
+	default:
+		return nil, fmt.Errorf("unexpected redaction mode: %s, should be one of: %s, %s", mode, ModeStrip, ModeMask)
+	}
+
+	for _, p := range properties {
+		if p == "" {
+			continue
+		}
+		parts := strings.Split(p, ".")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid redacted property: %s, should be Label.property", p)
+		}
+		if r.properties[parts[0]] == nil {
+			r.properties[parts[0]] = map[string]bool{}
+		}
+		r.properties[parts[0]][parts[1]] = true
+	}
+
+	if len(r.properties) == 0 {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// Record redacts a result record of the query, as a map of the column names to their values
+func (r *Redactor) Record(query *parser.CypherQuery, record map[string]interface{}) {
+	if r == nil {
+		return
+	}
+	columns := r.redactedColumns(query)
+	for k, v := range record {
+		if !columns[k] {
+			record[k] = r.Value(v)
+			continue
+		}
+		if r.mask {
+			record[k] = Mask
+		} else {
+			record[k] = nil
+		}
+	}
+}
+
+// Value redacts a value returned by Neo4j
+func (r *Redactor) Value(v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case neo4j.Node:
+		v.Props = r.props(v.Labels, v.Props)
+		return v
+	case neo4j.Relationship:
+		v.Props = r.props([]string{v.Type}, v.Props)
+		return v
+	case neo4j.Path:
+		nodes := make([]neo4j.Node, len(v.Nodes))
+		for i, n := range v.Nodes {
+			nodes[i] = r.Value(n).(neo4j.Node)
+		}
+		relationships := make([]neo4j.Relationship, len(v.Relationships))
+		for i, rel := range v.Relationships {
+			relationships[i] = r.Value(rel).(neo4j.Relationship)
+		}
+		return neo4j.Path{Nodes: nodes, Relationships: relationships}
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = r.Value(item)
+		}
+		return list
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = r.Value(item)
+		}
+		return m
+	}
+	return v
+}
+
+// props returns a copy of the properties of an element with the given labels, without the redacted ones
+func (r *Redactor) props(labels []string, props map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		redacted[k] = v
+		for _, label := range labels {
+			if !r.properties[label][k] {
+				continue
+			}
+			if r.mask {
+				redacted[k] = Mask
+			} else {
+				delete(redacted, k)
+			}
+			break
+		}
+	}
+	return redacted
+}
+
+// redactedColumns returns the columns of the query holding a redacted property, i.e. "p.born" in
+// "MATCH (p:Person) RETURN p.born" or "born" in "MATCH (p:Person) WITH p.born AS born RETURN born".
+// As a property value does not carry the label of its node, the property of a variable without label is redacted if
+// it is redacted for any label.
+func (r *Redactor) redactedColumns(query *parser.CypherQuery) map[string]bool {
+	columns := map[string]bool{}
+	for _, q := range query.Queries() {
+		// labels of the variables
+		labels := map[string][]string{}
+		q.WalkPatterns(func(node *parser.CypherNode, rel *parser.CypherRelationShip) {
+			addLabel(labels, node)
+			if rel != nil {
+				if rel.Props != nil {
+					addLabel(labels, rel.Props)
+				}
+				addLabel(labels, &rel.Target)
+			}
+		})
+
+		// variables holding a redacted property value
+		redacted := map[string]bool{}
+		for _, w := range q.With {
+			nextLabels := map[string][]string{}
+			nextRedacted := map[string]bool{}
+			for _, proj := range w.Projections {
+				if r.isRedacted(labels, redacted, &proj) {
+					nextRedacted[proj.Name()] = true
+				} else if proj.Function == nil && proj.Property == nil {
+					nextLabels[proj.Name()] = labels[proj.VariableName]
+				}
+			}
+			labels, redacted = nextLabels, nextRedacted
+			// the variables bound after the WITH
+			for _, m := range w.Matches {
+				addLabel(labels, &m.Node)
+				if m.Relationship != nil {
+					if m.Relationship.Props != nil {
+						addLabel(labels, m.Relationship.Props)
+					}
+					addLabel(labels, &m.Relationship.Target)
+				}
+			}
+		}
+
+		for _, ret := range q.Return {
+			if r.isRedacted(labels, redacted, &ret) {
+				columns[ret.Name()] = true
+			}
+		}
+	}
+	return columns
+}
+
+// isRedacted returns true if the projected element is a redacted property, or a variable holding one
+func (r *Redactor) isRedacted(labels map[string][]string, redacted map[string]bool, proj *parser.CypherVariableReturn) bool {
+	if proj.Function != nil && *proj.Function == "count" {
+		// counting does not disclose the values
+		return false
+	}
+	if proj.Property == nil {
+		return redacted[proj.VariableName]
+	}
+	variableLabels, ok := labels[proj.VariableName]
+	if !ok || len(variableLabels) == 0 {
+		for _, properties := range r.properties {
+			if properties[*proj.Property] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, label := range variableLabels {
+		if r.properties[label][*proj.Property] {
+			return true
+		}
+	}
+	return false
+}
+
+func addLabel(labels map[string][]string, node *parser.CypherNode) {
+	if node.VariableName == nil {
+		return
+	}
+	if _, ok := labels[*node.VariableName]; !ok {
+		labels[*node.VariableName] = []string{}
+	}
+	if node.TypeName != nil {
+		labels[*node.VariableName] = append(labels[*node.VariableName], *node.TypeName)
+	}
+}
@@ -0,0 +1,93 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/nzin/lexneo4j/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor(t *testing.T) {
+	tom := neo4j.Node{Id: 1, Labels: []string{"Person"}, Props: map[string]interface{}{"name": "Tom Hanks", "born": int64(1956)}}
+	matrix := neo4j.Node{Id: 2, Labels: []string{"Movie"}, Props: map[string]interface{}{"title": "The Matrix", "born": "not redacted"}}
+	actedIn := neo4j.Relationship{Id: 3, StartId: 1, EndId: 2, Type: "ACTED_IN", Props: map[string]interface{}{"roles": []interface{}{"Neo"}, "salary": int64(10)}}
+
+	r, err := NewRedactor([]string{"Person.born", "ACTED_IN.salary"}, ModeStrip)
+	assert.Nil(t, err)
+
+	t.Run("node", func(t *testing.T) {
+		redacted := r.Value(tom).(neo4j.Node)
+		assert.Equal(t, map[string]interface{}{"name": "Tom Hanks"}, redacted.Props)
+		assert.Equal(t, int64(1956), tom.Props["born"], "the original node must not be modified")
+		assert.Equal(t, matrix, r.Value(matrix))
+	})
+
+	t.Run("relationship", func(t *testing.T) {
+		redacted := r.Value(actedIn).(neo4j.Relationship)
+		assert.Equal(t, map[string]interface{}{"roles": []interface{}{"Neo"}}, redacted.Props)
+	})
+
+	t.Run("path, list and map", func(t *testing.T) {
+		path := r.Value(neo4j.Path{Nodes: []neo4j.Node{tom, matrix}, Relationships: []neo4j.Relationship{actedIn}}).(neo4j.Path)
+		assert.NotContains(t, path.Nodes[0].Props, "born")
+		assert.NotContains(t, path.Relationships[0].Props, "salary")
+
+		list := r.Value([]interface{}{tom, "foo"}).([]interface{})
+		assert.NotContains(t, list[0].(neo4j.Node).Props, "born")
+		assert.Equal(t, "foo", list[1])
+
+		m := r.Value(map[string]interface{}{"person": tom}).(map[string]interface{})
+		assert.NotContains(t, m["person"].(neo4j.Node).Props, "born")
+	})
+
+	t.Run("record", func(t *testing.T) {
+		query, err := parser.NewParser("MATCH (p:Person)-[:ACTED_IN]->(m:Movie) RETURN p, p.born, m.born, p.name").Parse()
+		assert.Nil(t, err)
+		record := map[string]interface{}{"p": tom, "p.born": int64(1956), "m.born": "not redacted", "p.name": "Tom Hanks"}
+		r.Record(query, record)
+		assert.NotContains(t, record["p"].(neo4j.Node).Props, "born")
+		assert.Nil(t, record["p.born"])
+		assert.Equal(t, "not redacted", record["m.born"])
+		assert.Equal(t, "Tom Hanks", record["p.name"])
+	})
+
+	t.Run("record with a WITH", func(t *testing.T) {
+		query, err := parser.NewParser("MATCH (p:Person) WITH p.born AS b, p AS q, count(p.born) AS c RETURN b, q.born, c").Parse()
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]bool{"b": true, "q.born": true}, r.redactedColumns(query))
+	})
+
+	t.Run("record with an unlabeled variable", func(t *testing.T) {
+		query, err := parser.NewParser("MATCH (m:Movie)<--(p) RETURN p.born, m.born").Parse()
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]bool{"p.born": true}, r.redactedColumns(query))
+	})
+
+	t.Run("mask", func(t *testing.T) {
+		masking, err := NewRedactor([]string{"Person.born"}, ModeMask)
+		assert.Nil(t, err)
+		redacted := masking.Value(tom).(neo4j.Node)
+		assert.Equal(t, Mask, redacted.Props["born"])
+
+		query, err := parser.NewParser("MATCH (p:Person) RETURN p.born").Parse()
+		assert.Nil(t, err)
+		record := map[string]interface{}{"p.born": int64(1956)}
+		masking.Record(query, record)
+		assert.Equal(t, Mask, record["p.born"])
+	})
+
+	t.Run("no redaction", func(t *testing.T) {
+		none, err := NewRedactor([]string{}, ModeStrip)
+		assert.Nil(t, err)
+		assert.Nil(t, none)
+		assert.Equal(t, tom, none.Value(tom))
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		_, err := NewRedactor([]string{"born"}, ModeStrip)
+		assert.NotNil(t, err)
+		_, err = NewRedactor([]string{"Person.born"}, "hide")
+		assert.NotNil(t, err)
+	})
+}
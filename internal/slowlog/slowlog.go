@@ -0,0 +1,82 @@
+package slowlog
+
+import (
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Entry is a query slower than the threshold
+type Entry struct {
+	// Query is the query as sent to neo4j
+	Query       string
+	Fingerprint string
+	Caller      string
+	Tenant      string
+	Duration    time.Duration
+	Rows        int
+	// Plan is the execution plan of the query, if it is captured
+	Plan interface{}
+}
+
+// Logger logs the queries slower than a threshold, to the application log or to a dedicated file
+type Logger struct {
+	logger    *logrus.Logger
+	threshold time.Duration
+	closer    io.Closer
+}
+
+// NewLogger returns a logger of the queries slower than threshold, writing JSON lines to the file at path (rotated
+// when it reaches 100MB), or to the application log if path is empty. It returns nil if threshold is not positive.
+func NewLogger(threshold time.Duration, path string) *Logger {
+	if threshold <= 0 {
+		return nil
+	}
+	if path == "" {
+		return &Logger{logger: logrus.StandardLogger(), threshold: threshold}
+	}
+
+	file := &lumberjack.Logger{Filename: path, MaxSize: 100, MaxBackups: 5}
+	logger := logrus.New()
+	logger.SetOutput(file)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	return &Logger{logger: logger, threshold: threshold, closer: file}
+}
+
+// IsSlow returns true if a query lasting duration is slower than the threshold. It is always false for a nil logger.
+func (l *Logger) IsSlow(duration time.Duration) bool {
+	return l != nil && duration >= l.threshold
+}
+
+// Log logs a slow query
+func (l *Logger) Log(e Entry) {
+	if l == nil {
+		return
+	}
+	fields := logrus.Fields{
+		"slow_query":  e.Query,
+		"fingerprint": e.Fingerprint,
+		"duration_ms": float64(e.Duration.Microseconds()) / 1000,
+		"rows":        e.Rows,
+	}
+	if e.Caller != "" {
+		fields["caller"] = e.Caller
+	}
+	if e.Tenant != "" {
+		fields["tenant"] = e.Tenant
+	}
+	if e.Plan != nil {
+		fields["plan"] = e.Plan
+	}
+	l.logger.WithFields(fields).Warnf("slow query: %v above %v", e.Duration.Round(time.Millisecond), l.threshold)
+}
+
+// Close closes the dedicated file, if any
+func (l *Logger) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
@@ -0,0 +1,56 @@
+package slowlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	t.Run("application log", func(t *testing.T) {
+		hook := test.NewGlobal()
+		defer logrus.StandardLogger().ReplaceHooks(logrus.LevelHooks{})
+
+		l := NewLogger(time.Second, "")
+		assert.False(t, l.IsSlow(999*time.Millisecond))
+		assert.True(t, l.IsSlow(time.Second))
+
+		l.Log(Entry{Query: "MATCH (m:Movie) RETURN m", Fingerprint: "MATCH (m:Movie) RETURN m", Caller: "alice", Duration: 1500 * time.Millisecond, Rows: 38})
+		entry := hook.LastEntry()
+		assert.NotNil(t, entry)
+		assert.Equal(t, logrus.WarnLevel, entry.Level)
+		assert.Equal(t, "slow query: 1.5s above 1s", entry.Message)
+		assert.Equal(t, "MATCH (m:Movie) RETURN m", entry.Data["slow_query"])
+		assert.Equal(t, "alice", entry.Data["caller"])
+		assert.Equal(t, 1500.0, entry.Data["duration_ms"])
+		assert.NotContains(t, entry.Data, "plan")
+		assert.NotContains(t, entry.Data, "tenant")
+	})
+
+	t.Run("dedicated file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "slow.log")
+		l := NewLogger(time.Millisecond, path)
+		l.Log(Entry{Query: "MATCH (m:Movie) RETURN m", Duration: time.Second, Plan: map[string]interface{}{"operator": "ProduceResults@neo4j"}})
+		assert.Nil(t, l.Close())
+
+		content, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		line := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(content, &line))
+		assert.Equal(t, "MATCH (m:Movie) RETURN m", line["slow_query"])
+		assert.Equal(t, map[string]interface{}{"operator": "ProduceResults@neo4j"}, line["plan"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		l := NewLogger(0, "")
+		assert.Nil(t, l)
+		assert.False(t, l.IsSlow(time.Hour))
+		assert.Nil(t, l.Close())
+	})
+}
@@ -0,0 +1,146 @@
+package stats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Other is the fingerprint under which the queries are counted once the table is full
+const Other = "<other>"
+
+// samples is the number of latest durations of a fingerprint kept to compute its percentiles
+const samples = 1000
+
+// QueryStats are the statistics of the queries sharing a fingerprint
+type QueryStats struct {
+	// ID is a short hash of the fingerprint
+	ID          string
+	Fingerprint string
+	Count       int64
+	Errors      int64
+	ErrorRate   float64
+	// P50 and P95 are the percentiles of the latest durations
+	P50       time.Duration
+	P95       time.Duration
+	TotalTime time.Duration
+	Rows      int64
+	MeanRows  float64
+}
+
+type entry struct {
+	count     int64
+	errors    int64
+	rows      int64
+	totalTime time.Duration
+	// durations is a ring buffer of the latest durations
+	durations []time.Duration
+	next      int
+}
+
+// Table aggregates the executions of the queries per fingerprint, in memory
+type Table struct {
+	mu              sync.Mutex
+	entries         map[string]*entry
+	maxFingerprints int
+}
+
+// NewTable returns a table tracking up to maxFingerprints fingerprints, the queries of the other ones being counted
+// under Other
+func NewTable(maxFingerprints int) *Table {
+	return &Table{entries: map[string]*entry{}, maxFingerprints: maxFingerprints}
+}
+
+// Record counts an execution of a query. A nil table does nothing.
+func (t *Table) Record(fingerprint string, duration time.Duration, rows int, failed bool) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[fingerprint]
+	if !ok {
+		if len(t.entries) >= t.maxFingerprints {
+			fingerprint = Other
+			e = t.entries[Other]
+		}
+		if e == nil {
+			e = &entry{}
+			t.entries[fingerprint] = e
+		}
+	}
+
+	e.count++
+	if failed {
+		e.errors++
+	}
+	e.rows += int64(rows)
+	e.totalTime += duration
+	if len(e.durations) < samples {
+		e.durations = append(e.durations, duration)
+	} else {
+		e.durations[e.next] = duration
+		e.next = (e.next + 1) % samples
+	}
+}
+
+// Snapshot returns the statistics of the fingerprints, the ones taking the most time first
+func (t *Table) Snapshot() []QueryStats {
+	if t == nil {
+		return []QueryStats{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := make([]QueryStats, 0, len(t.entries))
+	for fingerprint, e := range t.entries {
+		durations := append([]time.Duration{}, e.durations...)
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		snapshot = append(snapshot, QueryStats{
+			ID:          ID(fingerprint),
+			Fingerprint: fingerprint,
+			Count:       e.count,
+			Errors:      e.errors,
+			ErrorRate:   float64(e.errors) / float64(e.count),
+			P50:         percentile(durations, 0.50),
+			P95:         percentile(durations, 0.95),
+			TotalTime:   e.totalTime,
+			Rows:        e.rows,
+			MeanRows:    float64(e.rows) / float64(e.count),
+		})
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].TotalTime != snapshot[j].TotalTime {
+			return snapshot[i].TotalTime > snapshot[j].TotalTime
+		}
+		return snapshot[i].Fingerprint < snapshot[j].Fingerprint
+	})
+	return snapshot
+}
+
+// ID returns a short hash of a fingerprint, to refer to it i.e. in the logs
+func ID(fingerprint string) string {
+	hash := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(hash[:8])
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	table := NewTable(2)
+	for i := 1; i <= 20; i++ {
+		table.Record("MATCH (m:Movie) RETURN m.title", time.Duration(i)*time.Millisecond, 10, i%10 == 0)
+	}
+	table.Record("MATCH (p:Person) RETURN p.name", time.Second, 3, false)
+	table.Record("MATCH (p:Person) RETURN p.born", time.Millisecond, 1, false)
+	table.Record("MATCH (m:Movie) RETURN m.title", 0, 0, false)
+	table.Record("MATCH (p:Person) RETURN p.born", time.Millisecond, 1, true)
+
+	snapshot := table.Snapshot()
+	assert.Len(t, snapshot, 3)
+
+	assert.Equal(t, "MATCH (p:Person) RETURN p.name", snapshot[0].Fingerprint)
+	assert.Equal(t, int64(1), snapshot[0].Count)
+
+	movies := snapshot[1]
+	assert.Equal(t, "MATCH (m:Movie) RETURN m.title", movies.Fingerprint)
+	assert.Equal(t, ID("MATCH (m:Movie) RETURN m.title"), movies.ID)
+	assert.Len(t, movies.ID, 16)
+	assert.Equal(t, int64(21), movies.Count)
+	assert.Equal(t, int64(2), movies.Errors)
+	assert.InDelta(t, 2.0/21, movies.ErrorRate, 0.0001)
+	assert.Equal(t, 10*time.Millisecond, movies.P50)
+	assert.Equal(t, 19*time.Millisecond, movies.P95)
+	assert.Equal(t, int64(200), movies.Rows)
+	assert.InDelta(t, 200.0/21, movies.MeanRows, 0.0001)
+
+	// the table is full, the other fingerprints are counted together
+	other := snapshot[2]
+	assert.Equal(t, Other, other.Fingerprint)
+	assert.Equal(t, int64(2), other.Count)
+	assert.Equal(t, 0.5, other.ErrorRate)
+
+	t.Run("latest durations", func(t *testing.T) {
+		table := NewTable(1)
+		for i := 0; i < samples; i++ {
+			table.Record("q", time.Second, 0, false)
+		}
+		for i := 0; i < samples; i++ {
+			table.Record("q", time.Millisecond, 0, false)
+		}
+		assert.Equal(t, time.Millisecond, table.Snapshot()[0].P95)
+	})
+
+	t.Run("no table", func(t *testing.T) {
+		var table *Table
+		table.Record("q", time.Second, 0, false)
+		assert.Empty(t, table.Snapshot())
+	})
+}
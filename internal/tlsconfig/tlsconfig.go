@@ -0,0 +1,72 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// The verification modes of the client certificates
+const (
+	// ClientAuthNone does not ask for a client certificate
+	ClientAuthNone = "none"
+	// ClientAuthOptional verifies the client certificate if the client sends one
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire rejects the connections without a valid client certificate
+	ClientAuthRequire = "require"
+)
+
+// Server is the TLS configuration of the REST server
+type Server struct {
+	// CertFile and KeyFile are the PEM encoded certificate (with its chain) and private key of the server
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the PEM bundle of the certificate authorities trusted to verify the client certificates
+	ClientCAFile string
+	// ClientAuth is the verification mode of the client certificates: none, optional or require
+	ClientAuth string
+}
+
+// Apply sets the certificate of the server, and the verification of the client certificates, up. The settings that
+// are not set are left as is.
+func (s Server) Apply(tlsConfig *tls.Config) error {
+	if s.CertFile != "" || s.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return fmt.Errorf("cannot load the server certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch s.ClientAuth {
+	case "", ClientAuthNone:
+		return nil
+	case ClientAuthOptional:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return fmt.Errorf("unexpected client auth mode: %s, should be one of: none, optional, require", s.ClientAuth)
+	}
+	pool, err := LoadCertPool(s.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	tlsConfig.ClientCAs = pool
+	return nil
+}
+
+// LoadCertPool loads the certificates of a PEM bundle, i.e. the certificate authorities trusted to verify the neo4j
+// server
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("no PEM certificate found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,107 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nzin/lexneo4j/internal/tlsconfig/tlstest"
+	"github.com/stretchr/testify/assert"
+)
+
+// get calls a TLS server, with a client certificate if cert is not nil
+func get(t *testing.T, server *httptest.Server, ca *tlstest.CA, cert *tlstest.Cert) (int, error) {
+	clientConfig := &tls.Config{RootCAs: ca.Pool()}
+	if cert != nil {
+		clientConfig.Certificates = []tls.Certificate{cert.TLS}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func startServer(t *testing.T, s Server) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			rw.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	// the rejected handshakes are expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{}
+	assert.Nil(t, s.Apply(server.TLS))
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServer(t *testing.T) {
+	ca := tlstest.NewCA(t, "ca")
+	serverCert := ca.Server("server")
+	clientCert := ca.Client("client", pkix.Name{CommonName: "reporting"})
+	otherCert := tlstest.NewCA(t, "other").Client("client", pkix.Name{CommonName: "intruder"})
+
+	t.Run("tls", func(t *testing.T) {
+		server := startServer(t, Server{CertFile: serverCert.CertFile, KeyFile: serverCert.KeyFile})
+		status, err := get(t, server, ca, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, status)
+
+		// the server certificate is not trusted
+		_, err = get(t, server, tlstest.NewCA(t, "other"), nil)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("optional client certificate", func(t *testing.T) {
+		server := startServer(t, Server{CertFile: serverCert.CertFile, KeyFile: serverCert.KeyFile, ClientCAFile: ca.CertFile, ClientAuth: ClientAuthOptional})
+		status, err := get(t, server, ca, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		status, err = get(t, server, ca, clientCert)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, status)
+		// the client does not send a certificate issued by a CA the server does not trust
+		status, err = get(t, server, ca, otherCert)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("required client certificate", func(t *testing.T) {
+		server := startServer(t, Server{CertFile: serverCert.CertFile, KeyFile: serverCert.KeyFile, ClientCAFile: ca.CertFile, ClientAuth: ClientAuthRequire})
+		_, err := get(t, server, ca, nil)
+		assert.NotNil(t, err)
+		_, err = get(t, server, ca, otherCert)
+		assert.NotNil(t, err)
+		status, err := get(t, server, ca, clientCert)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, status)
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		assert.NotNil(t, Server{CertFile: serverCert.CertFile}.Apply(&tls.Config{}))
+		assert.NotNil(t, Server{ClientAuth: "always", ClientCAFile: ca.CertFile}.Apply(&tls.Config{}))
+		assert.NotNil(t, Server{ClientAuth: ClientAuthRequire}.Apply(&tls.Config{}))
+	})
+}
+
+func TestLoadCertPool(t *testing.T) {
+	ca := tlstest.NewCA(t, "ca")
+	pool, err := LoadCertPool(ca.CertFile)
+	assert.Nil(t, err)
+	assert.True(t, pool.Equal(ca.Pool()))
+
+	notPEM := filepath.Join(t.TempDir(), "ca.crt")
+	assert.Nil(t, os.WriteFile(notPEM, []byte("not a certificate"), 0600))
+	_, err = LoadCertPool(notPEM)
+	assert.Equal(t, "no PEM certificate found in "+notPEM, err.Error())
+}
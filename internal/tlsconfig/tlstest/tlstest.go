@@ -0,0 +1,135 @@
+// Package tlstest generates certificates for the tests, written as PEM files
+package tlstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// CA is a certificate authority issuing the certificates of a test
+type CA struct {
+	t    *testing.T
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	// CertFile is the PEM file of the certificate of the authority
+	CertFile string
+}
+
+// Cert is a certificate issued by a CA
+type Cert struct {
+	// CertFile and KeyFile are the PEM files of the certificate and of its private key
+	CertFile string
+	KeyFile  string
+	// TLS is the certificate, for a tls.Config
+	TLS tls.Certificate
+}
+
+// NewCA creates a certificate authority, its files being written in a temporary directory of the test
+func NewCA(t *testing.T, name string) *CA {
+	t.Helper()
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          nextSerial(),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &CA{t: t, dir: t.TempDir(), cert: cert, key: key}
+	ca.CertFile = ca.write(name+".crt", "CERTIFICATE", der)
+	return ca
+}
+
+// Server issues a certificate for a server, valid for localhost and 127.0.0.1
+func (ca *CA) Server(name string) *Cert {
+	return ca.issue(name, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+}
+
+// Client issues a certificate for a client with the given subject
+func (ca *CA) Client(name string, subject pkix.Name) *Cert {
+	return ca.issue(name, subject, x509.ExtKeyUsageClientAuth)
+}
+
+// Pool returns a pool holding the certificate of the authority
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca *CA) issue(name string, subject pkix.Name, usage x509.ExtKeyUsage) *Cert {
+	ca.t.Helper()
+	key := newKey(ca.t)
+	template := &x509.Certificate{
+		SerialNumber: nextSerial(),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+
+	cert := &Cert{
+		CertFile: ca.write(name+".crt", "CERTIFICATE", der),
+		KeyFile:  ca.write(name+".key", "EC PRIVATE KEY", keyDER),
+	}
+	cert.TLS, err = tls.LoadX509KeyPair(cert.CertFile, cert.KeyFile)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	return cert
+}
+
+func (ca *CA) write(name string, blockType string, der []byte) string {
+	path := filepath.Join(ca.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		ca.t.Fatal(err)
+	}
+	return path
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func nextSerial() *big.Int {
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	return serial
+}
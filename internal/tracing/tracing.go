@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the app
+const instrumentationName = "github.com/nzin/lexneo4j"
+
+// Config configures the export of the spans
+type Config struct {
+	// Enabled exports the spans with OTLP over HTTP. The exporter is configured with the standard OTEL_EXPORTER_OTLP_*
+	// environment variables, i.e. OTEL_EXPORTER_OTLP_ENDPOINT (http://localhost:4318 by default).
+	Enabled bool
+	// ServiceName is the name of the service of the spans
+	ServiceName string
+	// SampleRatio is the ratio of the traces sampled, when the caller did not decide it
+	SampleRatio float64
+}
+
+// Setup installs the W3C trace context propagation and, if enabled, the OTLP exporter. The returned function flushes
+// the spans and stops the exporter.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider := NewProvider(config, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider returns a tracer provider sampling the traces as configured, i.e. to export the spans to an in-memory
+// exporter in tests
+func NewProvider(config Config, options ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	options = append(options,
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	return sdktrace.NewTracerProvider(options...)
+}
+
+// Handler wraps the whole server, starting a span per incoming request, child of the span of the caller if the
+// request has a traceparent header
+func Handler(handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(handler, "lexneo4j",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+}
+
+// Start starts a span, child of the span of the context
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends a span, recording the error if there is one
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
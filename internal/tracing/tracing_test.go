@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(Config{ServiceName: "lexneo4j", SampleRatio: 1}, sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	_, err := Setup(context.Background(), Config{})
+	assert.Nil(t, err)
+
+	handler := Handler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "Parser.Parse")
+		End(span, errors.New("unexpected token"))
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/cypher", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Nil(t, provider.ForceFlush(context.Background()))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	parse, request := spans[0], spans[1]
+
+	assert.Equal(t, "POST /api/v1/cypher", request.Name)
+	assert.Equal(t, trace.SpanKindServer, request.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", request.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", request.Parent.SpanID().String())
+	assert.True(t, request.Parent.IsRemote())
+
+	assert.Equal(t, "Parser.Parse", parse.Name)
+	assert.Equal(t, request.SpanContext.SpanID(), parse.Parent.SpanID())
+	assert.Equal(t, codes.Error, parse.Status.Code)
+	assert.Len(t, parse.Events, 1)
+
+	t.Run("without traceparent", func(t *testing.T) {
+		exporter.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 2)
+		assert.False(t, spans[1].Parent.IsValid())
+	})
+}